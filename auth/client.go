@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+)
+
+var (
+	// ErrRejected is returned when the auth service does not accept a token.
+	ErrRejected = errors.New("token rejected")
+	// ErrUnavailable is returned while the auth service cannot be reached,
+	// so callers can answer 503 rather than blame the token.
+	ErrUnavailable = errors.New("auth service unavailable")
+)
+
+const (
+	// callTimeout bounds a single ping on top of the caller's context.
+	callTimeout = 3 * time.Second
+	// cacheTTL is how long an accepted token is trusted without asking again.
+	cacheTTL = 30 * time.Second
+	// rejectTTL is how long a rejected token is refused without asking again.
+	rejectTTL = 10 * time.Second
+	// cacheSize caps the number of remembered tokens.
+	cacheSize = 10000
+	// breakerThreshold consecutive failures open the circuit breaker.
+	breakerThreshold = 5
+	// breakerCooldown is how long an open breaker fails fast before letting a
+	// single probe through.
+	breakerCooldown = 15 * time.Second
+)
+
+type cacheEntry struct {
+	user    *data.AuthDto
+	expires time.Time
+}
+
+// Client asks the auth service at addr who a token belongs to. Answers are
+// cached by token hash, rejects included, and a circuit breaker stops calls
+// while the service keeps failing.
+type Client struct {
+	addr string
+	http *http.Client
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cacheEntry
+
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func NewClient(addr string) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 32
+
+	return &Client{
+		addr:  addr,
+		http:  &http.Client{Transport: transport},
+		cache: make(map[[sha256.Size]byte]cacheEntry),
+	}
+}
+
+// Ping returns the user token was issued to, ErrRejected when the auth
+// service refuses it and ErrUnavailable when the service cannot answer.
+func (c *Client) Ping(ctx context.Context, token string) (*data.AuthDto, error) {
+	key := sha256.Sum256([]byte(token))
+
+	user, cached := c.cached(key)
+	if cached {
+		if user == nil {
+			return nil, ErrRejected
+		}
+
+		return user, nil
+	}
+
+	if !c.allow() {
+		return nil, ErrUnavailable
+	}
+
+	user, err := c.ping(ctx, token)
+	switch {
+	case err == nil:
+		c.record(true)
+		c.store(key, user, cacheTTL)
+		return user, nil
+	case errors.Is(err, ErrRejected):
+		c.record(true)
+		c.store(key, nil, rejectTTL)
+		return nil, err
+	case ctx.Err() != nil:
+		// The caller went away; that says nothing about the auth service.
+		c.release()
+		return nil, ctx.Err()
+	default:
+		c.record(false)
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+}
+
+func (c *Client) ping(ctx context.Context, token string) (*data.AuthDto, error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("%v/ping", c.addr)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", token))
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		// Drain so the connection can be reused.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return nil, ErrRejected
+	default:
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		return nil, fmt.Errorf("auth service responded %v", resp.Status)
+	}
+
+	user := data.AuthDto{}
+
+	err = json.NewDecoder(resp.Body).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// cached returns the remembered answer for key. A nil user with cached set
+// is a remembered reject.
+func (c *Client) cached(key [sha256.Size]byte) (user *data.AuthDto, cached bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[key]
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(entry.expires) {
+		delete(c.cache, key)
+		return nil, false
+	}
+
+	return entry.user, true
+}
+
+func (c *Client) store(key [sha256.Size]byte, user *data.AuthDto, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if len(c.cache) >= cacheSize {
+		for k, entry := range c.cache {
+			if now.After(entry.expires) {
+				delete(c.cache, k)
+			}
+		}
+	}
+
+	// Still full of live entries: start over rather than grow without bound.
+	if len(c.cache) >= cacheSize {
+		clear(c.cache)
+	}
+
+	c.cache[key] = cacheEntry{user: user, expires: now.Add(ttl)}
+}
+
+// allow reports whether a call may go out. While the breaker is open calls
+// fail fast; once the cooldown is over a single probe is let through and
+// its outcome decides whether the breaker closes again.
+func (c *Client) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.failures < breakerThreshold {
+		return true
+	}
+
+	if c.probing || time.Now().Before(c.openUntil) {
+		return false
+	}
+
+	c.probing = true
+	return true
+}
+
+// release ends a call without judging the auth service by it.
+func (c *Client) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.probing = false
+}
+
+// record feeds the outcome of a call to the circuit breaker.
+func (c *Client) record(ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.probing = false
+
+	if ok {
+		c.failures = 0
+		return
+	}
+
+	c.failures++
+	if c.failures >= breakerThreshold {
+		c.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// authService answers pings with status, counting the calls it gets.
+func authService(t *testing.T, status *atomic.Int32) (*Client, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	id := uuid.New()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+
+		code := int(status.Load())
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"` + id.String() + `","name":"test"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient(server.URL), &calls
+}
+
+func statusOf(code int) *atomic.Int32 {
+	var status atomic.Int32
+	status.Store(int32(code))
+	return &status
+}
+
+func TestClientPing(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+	}{
+		{"accepted", http.StatusOK, nil},
+		{"unauthorized", http.StatusUnauthorized, ErrRejected},
+		{"forbidden", http.StatusForbidden, ErrRejected},
+		{"server error", http.StatusInternalServerError, ErrUnavailable},
+		{"bad gateway", http.StatusBadGateway, ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := authService(t, statusOf(tt.status))
+
+			user, err := client.Ping(context.Background(), "token")
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Ping: %v", err)
+			}
+
+			if user.Name != "test" {
+				t.Errorf("got user %+v", user)
+			}
+		})
+	}
+}
+
+func TestClientCachesAnswers(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+	}{
+		{"accepted", http.StatusOK, nil},
+		{"rejected", http.StatusUnauthorized, ErrRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, calls := authService(t, statusOf(tt.status))
+
+			for i := 0; i < 3; i++ {
+				_, err := client.Ping(context.Background(), "token")
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ping %v: got error %v, want %v", i, err, tt.wantErr)
+				}
+			}
+
+			if got := calls.Load(); got != 1 {
+				t.Errorf("auth service called %v times, want 1", got)
+			}
+
+			client.Ping(context.Background(), "other token")
+			if got := calls.Load(); got != 2 {
+				t.Errorf("auth service called %v times for another token, want 2", got)
+			}
+		})
+	}
+}
+
+func TestClientDoesNotCacheFailures(t *testing.T) {
+	status := statusOf(http.StatusInternalServerError)
+	client, calls := authService(t, status)
+
+	_, err := client.Ping(context.Background(), "token")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got error %v, want %v", err, ErrUnavailable)
+	}
+
+	status.Store(http.StatusOK)
+
+	_, err = client.Ping(context.Background(), "token")
+	if err != nil {
+		t.Fatalf("Ping after recovery: %v", err)
+	}
+
+	if got := calls.Load(); got != 2 {
+		t.Errorf("auth service called %v times, want 2", got)
+	}
+}
+
+func TestClientBreaker(t *testing.T) {
+	status := statusOf(http.StatusInternalServerError)
+	client, calls := authService(t, status)
+
+	for i := 0; i < breakerThreshold; i++ {
+		_, err := client.Ping(context.Background(), "token")
+		if !errors.Is(err, ErrUnavailable) {
+			t.Fatalf("ping %v: got error %v, want %v", i, err, ErrUnavailable)
+		}
+	}
+
+	// Open: fails fast without calling the auth service.
+	_, err := client.Ping(context.Background(), "token")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("open breaker: got error %v, want %v", err, ErrUnavailable)
+	}
+
+	if got := calls.Load(); got != breakerThreshold {
+		t.Fatalf("auth service called %v times, want %v", got, breakerThreshold)
+	}
+
+	// Cooldown over, but the probe fails: open again.
+	client.openUntil = time.Now()
+
+	_, err = client.Ping(context.Background(), "token")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("failed probe: got error %v, want %v", err, ErrUnavailable)
+	}
+
+	_, err = client.Ping(context.Background(), "token")
+	if !errors.Is(err, ErrUnavailable) || calls.Load() != breakerThreshold+1 {
+		t.Fatalf("after failed probe: got error %v after %v calls, want a fast failure", err, calls.Load())
+	}
+
+	// Cooldown over and the probe succeeds: closed.
+	client.openUntil = time.Now()
+	status.Store(http.StatusOK)
+
+	_, err = client.Ping(context.Background(), "token")
+	if err != nil {
+		t.Fatalf("successful probe: %v", err)
+	}
+
+	_, err = client.Ping(context.Background(), "other token")
+	if err != nil {
+		t.Fatalf("closed breaker: %v", err)
+	}
+
+	if got := calls.Load(); got != breakerThreshold+3 {
+		t.Errorf("auth service called %v times, want %v", got, breakerThreshold+3)
+	}
+}
+
+func TestClientRejectsCountAsHealthy(t *testing.T) {
+	client, calls := authService(t, statusOf(http.StatusUnauthorized))
+
+	for i := 0; i < breakerThreshold+1; i++ {
+		client.Ping(context.Background(), uuid.NewString())
+	}
+
+	if got := calls.Load(); got != breakerThreshold+1 {
+		t.Errorf("auth service called %v times, want %v", got, breakerThreshold+1)
+	}
+}
+
+func TestClientAllowsOneProbe(t *testing.T) {
+	client := NewClient("http://unused")
+	client.failures = breakerThreshold
+	client.openUntil = time.Now()
+
+	if !client.allow() {
+		t.Fatal("first call after the cooldown was refused")
+	}
+
+	if client.allow() {
+		t.Error("second call was let through while probing")
+	}
+
+	// A caller that went away says nothing about the service.
+	client.release()
+
+	if !client.allow() {
+		t.Error("call after a released probe was refused")
+	}
+}
+
+func TestClientCanceledCallKeepsBreakerClosed(t *testing.T) {
+	client, _ := authService(t, statusOf(http.StatusOK))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < breakerThreshold+1; i++ {
+		_, err := client.Ping(ctx, uuid.NewString())
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("got error %v, want %v", err, context.Canceled)
+		}
+	}
+
+	if client.failures != 0 {
+		t.Errorf("breaker counted %v failures, want 0", client.failures)
+	}
+}
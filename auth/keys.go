@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned for a token signed with a key the set does not
+// hold.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// refreshInterval limits how often a remote key set is fetched again when a
+// token names a key it does not know, so garbage kids cannot hammer the
+// endpoint.
+const refreshInterval = time.Minute
+
+// KeySet holds the RSA public keys RS256 tokens are verified with, read from
+// a JWKS document in a local file or at an http(s) URL.
+type KeySet struct {
+	source string
+	client *http.Client
+
+	mu      sync.RWMutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// LoadKeySet reads the JWKS document at source, a file path or an http(s)
+// URL. Remote sets are fetched again when a token names an unknown key.
+func LoadKeySet(ctx context.Context, source string) (*KeySet, error) {
+	s := &KeySet{
+		source: source,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+
+	err := s.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *KeySet) remote() bool {
+	return strings.HasPrefix(s.source, "http://") || strings.HasPrefix(s.source, "https://")
+}
+
+// Key returns the key with the given kid. A token without a kid is accepted
+// when the set holds exactly one key.
+func (s *KeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	key, stale := s.lookup(kid)
+	if key != nil {
+		return key, nil
+	}
+
+	if !s.remote() || !stale {
+		return nil, ErrUnknownKey
+	}
+
+	err := s.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key, _ = s.lookup(kid)
+	if key == nil {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+func (s *KeySet) lookup(kid string) (*rsa.PublicKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stale := time.Since(s.fetched) > refreshInterval
+
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, stale
+		}
+	}
+
+	return s.keys[kid], stale
+}
+
+func (s *KeySet) refresh(ctx context.Context) error {
+	body, err := s.read(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read key set: %w", err)
+	}
+
+	keys, err := parseKeySet(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse key set: %w", err)
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetched = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *KeySet) read(ctx context.Context) ([]byte, error) {
+	if !s.remote() {
+		return os.ReadFile(s.source)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v responded %v", s.source, resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// parseKeySet decodes the RSA signing keys of a JWKS document, skipping keys
+// of other types or uses.
+func parseKeySet(body []byte) (map[string]*rsa.PublicKey, error) {
+	document := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}{}
+
+	err := json.Unmarshal(body, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range document.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid modulus: %w", jwk.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid exponent: %w", jwk.Kid, err)
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("key %q: invalid exponent", jwk.Kid)
+		}
+
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(exponent.Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA signing keys")
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// leeway absorbs clock skew between us and the auth service when checking
+// exp and nbf.
+const leeway = 30 * time.Second
+
+// Claims are the claims the auth service puts in its tokens. The subject is
+// the user id.
+type Claims struct {
+	Name        string   `json:"name,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier checks tokens locally: HS256 tokens against the shared secret and
+// RS256 tokens against a key set.
+type Verifier struct {
+	secret []byte
+	keys   *KeySet
+	parser *jwt.Parser
+}
+
+// ErrNoKeys is returned by NewVerifier when given neither a secret nor a key
+// set, which would leave it nothing to check signatures against.
+var ErrNoKeys = errors.New("no token signing keys configured")
+
+// NewVerifier accepts HS256 when secret is set and RS256 when keys is set.
+// Tokens must carry exp, and aud must contain audience when it is not empty.
+func NewVerifier(secret []byte, keys *KeySet, audience string) (*Verifier, error) {
+	var methods []string
+	if len(secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if keys != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	// An empty list turns the whitelist off, which would let tokens through
+	// with any algorithm, an empty HMAC key included.
+	if len(methods) == 0 {
+		return nil, ErrNoKeys
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if audience != "" {
+		options = append(options, jwt.WithAudience(audience))
+	}
+
+	return &Verifier{
+		secret: secret,
+		keys:   keys,
+		parser: jwt.NewParser(options...),
+	}, nil
+}
+
+// Verify checks the signature, exp, nbf and aud of token and returns the
+// user it was issued to.
+func (v *Verifier) Verify(ctx context.Context, token string) (*data.AuthDto, error) {
+	claims := Claims{}
+
+	_, err := v.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if len(v.secret) == 0 {
+				return nil, ErrUnknownKey
+			}
+			return v.secret, nil
+		case *jwt.SigningMethodRSA:
+			if v.keys == nil {
+				return nil, ErrUnknownKey
+			}
+			kid, _ := t.Header["kid"].(string)
+			return v.keys.Key(ctx, kid)
+		default:
+			return nil, fmt.Errorf("unexpected signing method %v", t.Method.Alg())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid subject", jwt.ErrTokenInvalidClaims)
+	}
+
+	return &data.AuthDto{
+		Id:          id,
+		Name:        claims.Name,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+	}, nil
+}
+
+// Unverifiable reports whether err means the token could not be checked
+// locally at all, as opposed to being checked and rejected. Only such tokens
+// are worth asking the auth service about; an expired token stays expired.
+func Unverifiable(err error) bool {
+	return errors.Is(err, jwt.ErrTokenMalformed) ||
+		errors.Is(err, jwt.ErrTokenUnverifiable) ||
+		errors.Is(err, jwt.ErrTokenSignatureInvalid)
+}
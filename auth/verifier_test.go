@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	testSecret   = []byte("a-test-secret-that-is-long-enough")
+	testAudience = "culinary"
+	testKid      = "key-1"
+)
+
+// testKeys writes a JWKS document holding the public half of a new key and
+// loads it.
+func testKeys(t *testing.T) (*rsa.PrivateKey, *KeySet) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document, err := json.Marshal(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": testKid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	err = os.WriteFile(path, document, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadKeySet(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, keys
+}
+
+func claims(mutate func(c jwt.MapClaims)) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub":   uuid.NewString(),
+		"name":  "test",
+		"roles": []string{"curator"},
+		"aud":   testAudience,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+
+	if mutate != nil {
+		mutate(c)
+	}
+
+	return c
+}
+
+func signHS256(t *testing.T, key []byte, c jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, c jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestNewVerifierNeedsKeys(t *testing.T) {
+	_, err := NewVerifier(nil, nil, "")
+	if !errors.Is(err, ErrNoKeys) {
+		t.Errorf("got error %v, want %v", err, ErrNoKeys)
+	}
+
+	_, err = NewVerifier([]byte{}, nil, "")
+	if !errors.Is(err, ErrNoKeys) {
+		t.Errorf("empty secret: got error %v, want %v", err, ErrNoKeys)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key, keys := testKeys(t)
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+
+	none, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name             string
+		secret           []byte
+		keys             *KeySet
+		token            string
+		wantErr          error
+		wantUnverifiable bool
+	}{
+		{
+			name:   "HS256",
+			secret: testSecret,
+			token:  signHS256(t, testSecret, claims(nil)),
+		},
+		{
+			name:  "RS256",
+			keys:  keys,
+			token: signRS256(t, key, testKid, claims(nil)),
+		},
+		{
+			name:  "RS256 without kid from a single key set",
+			keys:  keys,
+			token: signRS256(t, key, "", claims(nil)),
+		},
+		{
+			name:             "HS256 with another secret",
+			secret:           testSecret,
+			token:            signHS256(t, []byte("another-secret"), claims(nil)),
+			wantErr:          jwt.ErrTokenSignatureInvalid,
+			wantUnverifiable: true,
+		},
+		{
+			name:             "alg none",
+			secret:           testSecret,
+			keys:             keys,
+			token:            none,
+			wantErr:          jwt.ErrTokenSignatureInvalid,
+			wantUnverifiable: true,
+		},
+		{
+			name:             "RS256 re-signed as HS256 with the public key",
+			secret:           testSecret,
+			keys:             keys,
+			token:            signHS256(t, publicPEM, claims(nil)),
+			wantErr:          jwt.ErrTokenSignatureInvalid,
+			wantUnverifiable: true,
+		},
+		{
+			name:             "HS256 without a secret",
+			keys:             keys,
+			token:            signHS256(t, publicPEM, claims(nil)),
+			wantErr:          jwt.ErrTokenSignatureInvalid,
+			wantUnverifiable: true,
+		},
+		{
+			name:             "RS256 without a key set",
+			secret:           testSecret,
+			token:            signRS256(t, key, testKid, claims(nil)),
+			wantErr:          jwt.ErrTokenSignatureInvalid,
+			wantUnverifiable: true,
+		},
+		{
+			name:             "unknown kid",
+			keys:             keys,
+			token:            signRS256(t, key, "key-2", claims(nil)),
+			wantErr:          ErrUnknownKey,
+			wantUnverifiable: true,
+		},
+		{
+			name:    "no exp",
+			secret:  testSecret,
+			token:   signHS256(t, testSecret, claims(func(c jwt.MapClaims) { delete(c, "exp") })),
+			wantErr: jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name:    "expired",
+			secret:  testSecret,
+			token:   signHS256(t, testSecret, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() })),
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name:   "expired within the leeway",
+			secret: testSecret,
+			token:  signHS256(t, testSecret, claims(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-10 * time.Second).Unix() })),
+		},
+		{
+			name:    "not valid yet",
+			secret:  testSecret,
+			token:   signHS256(t, testSecret, claims(func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Minute).Unix() })),
+			wantErr: jwt.ErrTokenNotValidYet,
+		},
+		{
+			name:    "wrong aud",
+			secret:  testSecret,
+			token:   signHS256(t, testSecret, claims(func(c jwt.MapClaims) { c["aud"] = "billing" })),
+			wantErr: jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name:    "missing aud",
+			secret:  testSecret,
+			token:   signHS256(t, testSecret, claims(func(c jwt.MapClaims) { delete(c, "aud") })),
+			wantErr: jwt.ErrTokenInvalidClaims,
+		},
+		{
+			name:    "sub not a uuid",
+			secret:  testSecret,
+			token:   signHS256(t, testSecret, claims(func(c jwt.MapClaims) { c["sub"] = "admin" })),
+			wantErr: jwt.ErrTokenInvalidClaims,
+		},
+		{
+			name:             "malformed",
+			secret:           testSecret,
+			token:            "not.a.token",
+			wantErr:          jwt.ErrTokenMalformed,
+			wantUnverifiable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := NewVerifier(tt.secret, tt.keys, testAudience)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := verifier.Verify(context.Background(), tt.token)
+
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+
+				if user.Name != "test" || len(user.Roles) != 1 || user.Roles[0] != "curator" {
+					t.Errorf("got user %+v", user)
+				}
+				return
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if got := Unverifiable(err); got != tt.wantUnverifiable {
+				t.Errorf("Unverifiable(%v) = %v, want %v", err, got, tt.wantUnverifiable)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutAudience(t *testing.T) {
+	verifier, err := NewVerifier(testSecret, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := signHS256(t, testSecret, claims(func(c jwt.MapClaims) { delete(c, "aud") }))
+
+	_, err = verifier.Verify(context.Background(), token)
+	if err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestUnverifiable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"malformed", jwt.ErrTokenMalformed, true},
+		{"unverifiable", jwt.ErrTokenUnverifiable, true},
+		{"bad signature", jwt.ErrTokenSignatureInvalid, true},
+		{"expired", jwt.ErrTokenExpired, false},
+		{"not valid yet", jwt.ErrTokenNotValidYet, false},
+		{"wrong audience", jwt.ErrTokenInvalidAudience, false},
+		{"invalid claims", jwt.ErrTokenInvalidClaims, false},
+		{"missing claim", jwt.ErrTokenRequiredClaimMissing, false},
+		{"other", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Unverifiable(tt.err)
+			if got != tt.want {
+				t.Errorf("Unverifiable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
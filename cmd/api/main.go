@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	_ "time/tzdata"
 
 	"github.com/adamelfsborg-code/food/culinary/config"
 	"github.com/adamelfsborg-code/food/culinary/server"
@@ -19,7 +20,11 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	server := server.New(*env)
+	server, err := server.New(*env)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	err = server.Start(ctx)
 	if err != nil {
 		log.Fatal(err)
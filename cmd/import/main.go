@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/adamelfsborg-code/food/culinary/config"
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/importer"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+const usage = `usage: import -user id [-update=false] [-limit n] <command>
+
+commands:
+  off <file>  import an Open Food Facts export (.jsonl, .csv, optionally .gz)`
+
+func main() {
+	user := flag.String("user", "", "id of the user recorded as creator of imported rows")
+	update := flag.Bool("update", true, "refresh nutrition of foods already in the catalogue")
+	limit := flag.Int("limit", 0, "stop after this many rows, 0 imports everything")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) != 2 || args[0] != "off" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	userId, err := uuid.Parse(*user)
+	if err != nil {
+		log.Fatalf("invalid -user: %v", err)
+	}
+
+	env, err := config.Database()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conn := pg.Connect(&pg.Options{
+		Addr:     env.DatabaseAddr,
+		Database: env.DatabaseName,
+		User:     env.DatabaseUser,
+		Password: env.DatabasePassword,
+	})
+	defer conn.Close()
+
+	dataConn := &data.DataConn{Env: *env, DB: *conn}
+
+	path, err := filepath.Abs(args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	source, file, err := importer.Open(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	// Running the CLI needs database credentials, so it may refresh foods
+	// whoever created them.
+	im := importer.New(dataConn, data.Actor{Id: userId, Roles: []string{data.RoleAdmin}})
+	im.Update = *update
+	im.Limit = *limit
+
+	report, runErr := im.Run(context.Background(), source)
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(out))
+
+	if runErr != nil {
+		log.Fatal(runErr)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/adamelfsborg-code/food/culinary/config"
+	"github.com/adamelfsborg-code/food/culinary/db"
+	"github.com/go-pg/pg/v10"
+)
+
+const usage = `usage: migrate [-dir path] <command>
+
+commands:
+  up             apply all pending migrations
+  down [steps]   roll back the last steps migrations (default 1)
+  status         list migrations and when they were applied
+  create <name>  write a new empty up/down pair to -dir`
+
+func main() {
+	dir := flag.String("dir", "db/migrations", "migration source directory used by create")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, usage)
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+
+		paths, err := db.CreateMigration(*dir, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, path := range paths {
+			fmt.Println("Created", path)
+		}
+		return
+	}
+
+	env, err := config.Database()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conn := pg.Connect(&pg.Options{
+		Addr:     env.DatabaseAddr,
+		Database: env.DatabaseName,
+		User:     env.DatabaseUser,
+		Password: env.DatabasePassword,
+	})
+	defer conn.Close()
+
+	migrator, err := db.NewMigrator(conn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		done, err := migrator.Up(ctx)
+		for _, migration := range done {
+			fmt.Printf("Applied %04d_%v\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid steps %q", args[1])
+			}
+		}
+
+		done, err := migrator.Down(ctx, steps)
+		for _, migration := range done {
+			fmt.Printf("Rolled back %04d_%v\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, migration := range status {
+			applied := "pending"
+			if migration.AppliedAt != nil {
+				applied = migration.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30v %v\n", migration.Version, migration.Name, applied)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -3,28 +3,44 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Environments struct {
-	ServerAddr       string
-	DatabaseAddr     string
-	DatabaseUser     string
-	DatabasePassword string
-	DatabaseName     string
-	SecretKey        []byte
-	NatsAddr         string
-	AuthAddr         string
+	ServerAddr         string
+	DatabaseAddr       string
+	DatabaseUser       string
+	DatabasePassword   string
+	DatabaseName       string
+	SecretKey          []byte
+	NatsAddr           string
+	EventsStream       string
+	EventsSubject      string
+	AuthAddr           string
+	AuthPingFallback   bool
+	JWTKeys            string
+	JWTAudience        string
+	RequireSchema      bool
+	ImportDir          string
+	ServiceTokens      []string
+	UserEventsStream   string
+	UserEventsSubject  string
+	UserEventRetention time.Duration
+	TrashRetention     time.Duration
+	OutboxRetention    time.Duration
+	RequireIfMatch     bool
 }
 
 var Env *Environments
 
 func New() (*Environments, error) {
-	err := godotenv.Load(".env")
-
+	env, err := Database()
 	if err != nil {
-		fmt.Printf("Failed to start server: %v", err)
+		return nil, err
 	}
 
 	serverAddr, exists := os.LookupEnv("SERVER_ADDR")
@@ -32,6 +48,175 @@ func New() (*Environments, error) {
 		return nil, fmt.Errorf("SERVER_ADDR not found")
 	}
 
+	natsAddr, exists := os.LookupEnv("NATS_ADDR")
+	if !exists {
+		return nil, fmt.Errorf("NATS_ADDR not found")
+	}
+
+	// Optional: JetStream stream catalogue events are published to, and the
+	// subject prefix they are published below.
+	eventsStream := "CULINARY"
+	if value, exists := os.LookupEnv("EVENTS_STREAM"); exists {
+		eventsStream = value
+	}
+
+	// Below "culinary.events" rather than "culinary", whose other subjects
+	// carry the requests of the NATS service API.
+	eventsSubject := "culinary.events"
+	if value, exists := os.LookupEnv("EVENTS_SUBJECT"); exists {
+		eventsSubject = value
+	}
+
+	// Optional: JetStream stream of the auth service carrying user.deleted
+	// and user.merged events, and the subject prefix they are published
+	// below. User lifecycle events are not consumed when it is not set.
+	userEventsStream := os.Getenv("USER_EVENTS_STREAM")
+
+	userEventsSubject := "user"
+	if value, exists := os.LookupEnv("USER_EVENTS_SUBJECT"); exists {
+		userEventsSubject = value
+	}
+
+	// Optional: how long applied user events are remembered, so a
+	// redelivery is not applied twice. It has to outlast the redelivery of
+	// the consumer, which gives up after minutes.
+	userEventRetention := 30 * 24 * time.Hour
+	if value, exists := os.LookupEnv("USER_EVENT_RETENTION"); exists {
+		userEventRetention, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("USER_EVENT_RETENTION: %w", err)
+		}
+
+		if userEventRetention <= 0 {
+			return nil, fmt.Errorf("USER_EVENT_RETENTION must be positive")
+		}
+	}
+
+	// Optional: JWKS file or URL with the RSA keys of RS256 tokens. Only
+	// HS256 tokens signed with SECRET_KEY are accepted when it is not set.
+	jwtKeys := os.Getenv("JWT_KEYS")
+
+	// Required unless JWT_KEYS is set, in which case only RS256 tokens are
+	// accepted when it is not. An empty key would sign anyone's tokens.
+	secretKey, exists := os.LookupEnv("SECRET_KEY")
+	if !exists && jwtKeys == "" {
+		return nil, fmt.Errorf("SECRET_KEY not found")
+	}
+
+	if exists && secretKey == "" {
+		return nil, fmt.Errorf("SECRET_KEY is empty")
+	}
+
+	// Optional: audience tokens must be issued for.
+	jwtAudience := os.Getenv("JWT_AUDIENCE")
+
+	// Optional: ask AUTH_ADDR about tokens that cannot be verified locally.
+	authPingFallback := false
+	if value, exists := os.LookupEnv("AUTH_PING_FALLBACK"); exists {
+		authPingFallback, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_PING_FALLBACK: %w", err)
+		}
+	}
+
+	authAddr, exists := os.LookupEnv("AUTH_ADDR")
+	if !exists && authPingFallback {
+		return nil, fmt.Errorf("AUTH_ADDR not found")
+	}
+
+	// Optional: refuse to start while migrations are pending.
+	requireSchema := false
+	if value, exists := os.LookupEnv("SERVER_REQUIRE_SCHEMA"); exists {
+		requireSchema, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("SERVER_REQUIRE_SCHEMA: %w", err)
+		}
+	}
+
+	// Optional: directory the admin import endpoint may read dumps from.
+	// Importing over HTTP is disabled when it is not set.
+	importDir := os.Getenv("IMPORT_DIR")
+
+	// Optional: comma separated tokens sibling services authenticate with on
+	// the NATS service API. The API is not served when it is not set.
+	var serviceTokens []string
+	for _, token := range strings.Split(os.Getenv("SERVICE_TOKENS"), ",") {
+		token = strings.TrimSpace(token)
+		if token != "" {
+			serviceTokens = append(serviceTokens, token)
+		}
+	}
+
+	// Optional: how long deleted rows stay in the trash before they are
+	// purged for good.
+	trashRetention := 30 * 24 * time.Hour
+	if value, exists := os.LookupEnv("TRASH_RETENTION"); exists {
+		trashRetention, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("TRASH_RETENTION: %w", err)
+		}
+
+		if trashRetention <= 0 {
+			return nil, fmt.Errorf("TRASH_RETENTION must be positive")
+		}
+	}
+
+	// Optional: how long published events stay in the outbox, where the
+	// admin API can still list them.
+	outboxRetention := 7 * 24 * time.Hour
+	if value, exists := os.LookupEnv("OUTBOX_RETENTION"); exists {
+		outboxRetention, err = time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("OUTBOX_RETENTION: %w", err)
+		}
+
+		if outboxRetention <= 0 {
+			return nil, fmt.Errorf("OUTBOX_RETENTION must be positive")
+		}
+	}
+
+	// Optional: reject edits and deletes sent without If-Match with 428
+	// instead of applying them unconditionally.
+	requireIfMatch := false
+	if value, exists := os.LookupEnv("REQUIRE_IF_MATCH"); exists {
+		requireIfMatch, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("REQUIRE_IF_MATCH: %w", err)
+		}
+	}
+
+	env.ServerAddr = serverAddr
+	env.SecretKey = []byte(secretKey)
+	env.NatsAddr = natsAddr
+	env.EventsStream = eventsStream
+	env.EventsSubject = eventsSubject
+	env.AuthAddr = authAddr
+	env.AuthPingFallback = authPingFallback
+	env.JWTKeys = jwtKeys
+	env.JWTAudience = jwtAudience
+	env.RequireSchema = requireSchema
+	env.ImportDir = importDir
+	env.ServiceTokens = serviceTokens
+	env.UserEventsStream = userEventsStream
+	env.UserEventsSubject = userEventsSubject
+	env.UserEventRetention = userEventRetention
+	env.TrashRetention = trashRetention
+	env.OutboxRetention = outboxRetention
+	env.RequireIfMatch = requireIfMatch
+
+	Env = env
+	return env, nil
+}
+
+// Database reads only the DATABASE_* variables, for commands that talk to
+// the database without serving anything. The other fields are left zero.
+func Database() (*Environments, error) {
+	err := godotenv.Load(".env")
+
+	if err != nil {
+		fmt.Printf("Failed to load .env: %v", err)
+	}
+
 	databaseAddr, exists := os.LookupEnv("DATABASE_ADDR")
 	if !exists {
 		return nil, fmt.Errorf("DATABASE_ADDR not found")
@@ -52,32 +237,12 @@ func New() (*Environments, error) {
 		return nil, fmt.Errorf("DATABASE_NAME not found")
 	}
 
-	secretKey, exists := os.LookupEnv("SECRET_KEY")
-	if !exists {
-		return nil, fmt.Errorf("SECRET_KEY not found")
-	}
-
-	natsAddr, exists := os.LookupEnv("NATS_ADDR")
-	if !exists {
-		return nil, fmt.Errorf("NATS_ADDR not found")
-	}
-
-	authAddr, exists := os.LookupEnv("AUTH_ADDR")
-	if !exists {
-		return nil, fmt.Errorf("AUTH_ADDR not found")
-	}
-
 	env := &Environments{
-		ServerAddr:       serverAddr,
 		DatabaseAddr:     databaseAddr,
 		DatabaseUser:     databaseUser,
 		DatabasePassword: databasePassword,
 		DatabaseName:     databaseName,
-		SecretKey:        []byte(secretKey),
-		NatsAddr:         natsAddr,
-		AuthAddr:         authAddr,
 	}
 
-	Env = env
 	return env, nil
 }
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+)
+
+const (
+	// RoleAdmin may edit and delete rows created by anyone and holds every
+	// permission.
+	RoleAdmin = "admin"
+	// RoleCurator maintains the shared taxonomy: categories, food types and
+	// nutrients.
+	RoleCurator = "curator"
+)
+
+// Permission names an action granted by role rather than by owning a row.
+type Permission string
+
+const (
+	PermTaxonomyWrite Permission = "taxonomy:write"
+	PermNutrientWrite Permission = "nutrient:write"
+	PermImport        Permission = "import:run"
+	PermOutbox        Permission = "outbox:manage"
+	PermTrashPurge    Permission = "trash:purge"
+)
+
+// rolePermissions lists what each role grants on top of the permissions the
+// auth service hands out directly.
+var rolePermissions = map[string][]Permission{
+	RoleCurator: {PermTaxonomyWrite, PermNutrientWrite},
+}
+
+// ErrForbidden is returned when a row exists but the actor may not change it.
+var ErrForbidden = errors.New("forbidden")
+
+// Actor is the authenticated caller a write is made on behalf of. RequestId
+// identifies the request it was made in, and is recorded in the audit log.
+type Actor struct {
+	Id          uuid.UUID
+	Roles       []string
+	Permissions []string
+	RequestId   string
+}
+
+func (a Actor) HasRole(role string) bool {
+	return slices.Contains(a.Roles, role)
+}
+
+// Elevated reports whether the actor may change rows it does not own.
+func (a Actor) Elevated() bool {
+	return a.HasRole(RoleAdmin)
+}
+
+// Can reports whether the actor holds perm, directly or through one of its
+// roles. Admins hold every permission.
+func (a Actor) Can(perm Permission) bool {
+	if a.Elevated() || slices.Contains(a.Permissions, string(perm)) {
+		return true
+	}
+
+	for _, role := range a.Roles {
+		if slices.Contains(rolePermissions[role], perm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// owns limits an UPDATE or DELETE to rows created by the actor, unless it is
+// elevated. Checking ownership in the statement itself leaves no window
+// between the check and the write.
+func (a Actor) owns(q *orm.Query) (*orm.Query, error) {
+	if a.Elevated() {
+		return q, nil
+	}
+
+	return q.Where(`?TableAlias."user" = ?`, a.Id), nil
+}
+
+// mayChange fails with ErrForbidden unless the actor may change a row created
+// by owner. Writes that check the version of a row they have read check this
+// first, so a stale If-Match does not tell others the row's version.
+func (a Actor) mayChange(owner uuid.UUID) error {
+	if a.Elevated() || owner == a.Id {
+		return nil
+	}
+
+	return ErrForbidden
+}
+
+// missingOrForbidden explains why a write limited by Actor.owns matched no
+// row: pg.ErrNoRows when there is no row with id, ErrForbidden otherwise.
+// Rows in the trash count as missing.
+func missingOrForbidden(db orm.DB, model any, id uuid.UUID) error {
+	q := db.Model(model).Where("?TableAlias.id = ?", id)
+	if hasTrash(q) {
+		q = q.Apply(alive)
+	}
+
+	exists, err := q.Exists()
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return pg.ErrNoRows
+	}
+
+	return ErrForbidden
+}
+
+// ownedResult checks the outcome of an UPDATE or DELETE limited by
+// Actor.owns, turning zero affected rows into missingOrForbidden.
+func ownedResult(db orm.DB, model any, id uuid.UUID, res orm.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return missingOrForbidden(db, model, id)
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/adamelfsborg-code/food/culinary/events"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+)
+
+// AuditDto is one change in the history of an entity. Before is empty for
+// created entries and After for deleted ones. Changes lists the fields that
+// differ between the two.
+//
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type AuditDto struct {
+	tableName struct{}         `pg:"core.audit_log,alias:a"`
+	Id        uuid.UUID        `json:"id" pg:"id"`
+	Timestamp time.Time        `json:"timestamp" pg:"timestamp"`
+	Entity    string           `json:"entity" pg:"entity"`
+	EntityId  string           `json:"entityId" pg:"entity_id"`
+	Action    string           `json:"action" pg:"action"`
+	Actor     uuid.UUID        `json:"actor" pg:"actor"`
+	RequestId *string          `json:"requestId" pg:"request_id"`
+	Before    json.RawMessage  `json:"before,omitempty" pg:"before,type:jsonb"`
+	After     json.RawMessage  `json:"after,omitempty" pg:"after,type:jsonb"`
+	Changes   []FieldChangeDto `json:"changes" pg:"-"`
+}
+
+// FieldChangeDto is a field whose value differs between two versions of an
+// entity. Fields of nested objects are named by their path, e.g.
+// "nutrients.vitamin_c".
+type FieldChangeDto struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+var auditSortColumns = sortColumns{
+	"timestamp": "a.timestamp",
+}
+
+func (a AuditDto) cursor() Cursor {
+	return Cursor{Timestamp: a.Timestamp, Id: a.Id}
+}
+
+// addAudit stores event in the audit log inside tx, so the log holds exactly
+// the changes that were committed.
+func addAudit(tx *pg.Tx, event events.Event, requestId string) error {
+	action := strings.TrimPrefix(event.Type, event.Entity+".")
+
+	row := AuditDto{
+		Id:        event.Id,
+		Timestamp: event.Timestamp,
+		Entity:    event.Entity,
+		EntityId:  event.EntityId,
+		Action:    action,
+		Actor:     event.Actor,
+		Before:    event.Before,
+		After:     event.After,
+	}
+
+	if requestId != "" {
+		row.RequestId = &requestId
+	}
+
+	_, err := tx.Model(&row).Insert()
+	return err
+}
+
+func historyOf(entity string, id uuid.UUID) func(q *orm.Query) (*orm.Query, error) {
+	return func(q *orm.Query) (*orm.Query, error) {
+		return q.Where("a.entity = ?", entity).Where("a.entity_id = ?", id.String()), nil
+	}
+}
+
+// ListHistory lists the changes to the entity with id, newest first, with the
+// fields each of them changed.
+func (d *DataConn) ListHistory(entity string, id uuid.UUID, page Page) ([]AuditDto, Cursors, error) {
+	var rows []AuditDto
+
+	err := d.DB.Model(&rows).Apply(historyOf(entity, id)).Apply(page.apply(auditSortColumns)).Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	rows, cursors := paginate(rows, page)
+	for i := range rows {
+		rows[i].Changes = diffFields("", rows[i].Before, rows[i].After, []FieldChangeDto{})
+	}
+
+	return rows, cursors, nil
+}
+
+func (d *DataConn) CountHistory(entity string, id uuid.UUID) (int, error) {
+	var rows []AuditDto
+
+	count, err := d.DB.Model(&rows).Apply(historyOf(entity, id)).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// diffFields appends the fields that differ between the JSON values before
+// and after to changes. Objects are compared field by field, anything else
+// as a whole.
+func diffFields(field string, before, after json.RawMessage, changes []FieldChangeDto) []FieldChangeDto {
+	if len(before) == 0 {
+		before = json.RawMessage("null")
+	}
+
+	if len(after) == 0 {
+		after = json.RawMessage("null")
+	}
+
+	var beforeFields, afterFields map[string]json.RawMessage
+	beforeErr := json.Unmarshal(before, &beforeFields)
+	afterErr := json.Unmarshal(after, &afterFields)
+
+	// A leaf, or an object replaced by something else.
+	if beforeErr != nil || afterErr != nil {
+		if !bytes.Equal(before, after) {
+			changes = append(changes, FieldChangeDto{Field: field, Before: before, After: after})
+		}
+
+		return changes
+	}
+
+	var names []string
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	for _, name := range names {
+		path := name
+		if field != "" {
+			path = field + "." + name
+		}
+
+		changes = diffFields(path, beforeFields[name], afterFields[name], changes)
+	}
+
+	return changes
+}
+
+// version reads the state change left the entity with id in into dst.
+func (d *DataConn) version(entity string, id, change uuid.UUID, dst any) error {
+	var row AuditDto
+
+	err := d.DB.Model(&row).Where("a.id = ?", change).Apply(historyOf(entity, id)).Select()
+	if err != nil {
+		return err
+	}
+
+	if len(row.After) == 0 || string(row.After) == "null" {
+		return conflict("change %v deleted the %v, there is nothing to revert to", change, entity)
+	}
+
+	return json.Unmarshal(row.After, dst)
+}
+
+// RevertBrand edits the brand with id back to the state it was left in by
+// change, recording the revert as a change of its own. Like EditBrand it only
+// goes ahead while the brand is at version.
+func (d *DataConn) RevertBrand(actor Actor, id, change uuid.UUID, version int) error {
+	var brand BrandDto
+
+	err := d.version("brand", id, change, &brand)
+	if err != nil {
+		return err
+	}
+
+	return d.EditBrand(actor, id, version, brand.Name)
+}
+
+// RevertCategory is RevertBrand for categories.
+func (d *DataConn) RevertCategory(actor Actor, id, change uuid.UUID, version int) error {
+	var category CategoryDto
+
+	err := d.version("category", id, change, &category)
+	if err != nil {
+		return err
+	}
+
+	return d.EditCategory(actor, id, version, category.Name)
+}
+
+// RevertFoodType is RevertBrand for food types.
+func (d *DataConn) RevertFoodType(actor Actor, id, change uuid.UUID, version int) error {
+	var foodType FoodTypeDto
+
+	err := d.version("foodtype", id, change, &foodType)
+	if err != nil {
+		return err
+	}
+
+	return d.EditFoodType(actor, id, version, foodType.Name, foodType.Category)
+}
+
+// RevertFood is RevertBrand for foods, including their nutrient values and
+// barcodes.
+func (d *DataConn) RevertFood(actor Actor, id, change uuid.UUID, version int) error {
+	var food FoodDto
+
+	err := d.version("food", id, change, &food)
+	if err != nil {
+		return err
+	}
+
+	// Left out of the snapshot when it had none, which has to clear them.
+	if food.Nutrients == nil {
+		food.Nutrients = map[string]float32{}
+	}
+
+	if food.Barcodes == nil {
+		food.Barcodes = []string{}
+	}
+
+	return d.EditFood(actor, food.Name, food.KCAL, food.Protein, food.Carbs, food.Fat, food.Saturated, food.Unsaturated, food.Fiber, food.Sugars, food.Nutrients, food.Barcodes, food.Brand, food.FoodType, id, version)
+}
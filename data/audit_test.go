@@ -0,0 +1,109 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []FieldChangeDto
+	}{
+		{
+			name:   "unchanged",
+			before: `{"name":"Oat","kcal":380}`,
+			after:  `{"name":"Oat","kcal":380}`,
+			want:   []FieldChangeDto{},
+		},
+		{
+			name:   "changed field",
+			before: `{"name":"Oat","kcal":380}`,
+			after:  `{"name":"Oats","kcal":380}`,
+			want:   []FieldChangeDto{{Field: "name", Before: json.RawMessage(`"Oat"`), After: json.RawMessage(`"Oats"`)}},
+		},
+		{
+			name:   "fields in name order",
+			before: `{"name":"Oat","kcal":380}`,
+			after:  `{"name":"Oats","kcal":370}`,
+			want: []FieldChangeDto{
+				{Field: "kcal", Before: json.RawMessage(`380`), After: json.RawMessage(`370`)},
+				{Field: "name", Before: json.RawMessage(`"Oat"`), After: json.RawMessage(`"Oats"`)},
+			},
+		},
+		{
+			name:   "added and removed fields",
+			before: `{"a":1}`,
+			after:  `{"b":2}`,
+			want: []FieldChangeDto{
+				{Field: "a", Before: json.RawMessage(`1`), After: json.RawMessage(`null`)},
+				{Field: "b", Before: json.RawMessage(`null`), After: json.RawMessage(`2`)},
+			},
+		},
+		{
+			name:   "nested field",
+			before: `{"nutrients":{"iron":2,"zinc":1}}`,
+			after:  `{"nutrients":{"iron":3,"zinc":1}}`,
+			want:   []FieldChangeDto{{Field: "nutrients.iron", Before: json.RawMessage(`2`), After: json.RawMessage(`3`)}},
+		},
+		{
+			name:   "object replaced by a value",
+			before: `{"brand":{"id":"x"}}`,
+			after:  `{"brand":"x"}`,
+			want:   []FieldChangeDto{{Field: "brand", Before: json.RawMessage(`{"id":"x"}`), After: json.RawMessage(`"x"`)}},
+		},
+		{
+			name:   "object cleared",
+			before: `{"brand":{"id":"x"}}`,
+			after:  `{"brand":null}`,
+			want:   []FieldChangeDto{{Field: "brand.id", Before: json.RawMessage(`"x"`), After: json.RawMessage(`null`)}},
+		},
+		{
+			name:   "arrays compared whole",
+			before: `{"barcodes":["1","2"]}`,
+			after:  `{"barcodes":["2","1"]}`,
+			want:   []FieldChangeDto{{Field: "barcodes", Before: json.RawMessage(`["1","2"]`), After: json.RawMessage(`["2","1"]`)}},
+		},
+		{
+			name:   "created",
+			before: ``,
+			after:  `{"name":"Oat"}`,
+			want:   []FieldChangeDto{{Field: "name", Before: json.RawMessage(`null`), After: json.RawMessage(`"Oat"`)}},
+		},
+		{
+			name:   "deleted",
+			before: `{"name":"Oat"}`,
+			after:  `null`,
+			want:   []FieldChangeDto{{Field: "name", Before: json.RawMessage(`"Oat"`), After: json.RawMessage(`null`)}},
+		},
+		{
+			name:   "both empty",
+			before: ``,
+			after:  ``,
+			want:   []FieldChangeDto{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffFields("", json.RawMessage(tt.before), json.RawMessage(tt.after), []FieldChangeDto{})
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v changes %s, want %v", len(got), mustJSON(got), len(tt.want))
+			}
+
+			for i := range got {
+				if got[i].Field != tt.want[i].Field || string(got[i].Before) != string(tt.want[i].Before) || string(got[i].After) != string(tt.want[i].After) {
+					t.Errorf("change %v: got %s, want %s", i, mustJSON(got[i]), mustJSON(tt.want[i]))
+				}
+			}
+		})
+	}
+}
+
+func mustJSON(v any) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
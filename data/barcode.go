@@ -0,0 +1,119 @@
+package data
+
+import (
+	"github.com/adamelfsborg-code/food/culinary/gtin"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type FoodBarcodeDto struct {
+	tableName struct{}  `pg:"core.food_barcode,alias:fb"`
+	Code      string    `json:"code" pg:"code,pk"`
+	Food      uuid.UUID `json:"food" pg:"food"`
+}
+
+// normalizeBarcodes validates every code and returns their GTIN-14 forms
+// without duplicates, keeping nil as nil so callers can tell "leave as is"
+// apart from "remove all".
+func normalizeBarcodes(codes []string) ([]string, error) {
+	if codes == nil {
+		return nil, nil
+	}
+
+	seen := map[string]bool{}
+	normalized := make([]string, 0, len(codes))
+	for _, code := range codes {
+		n, err := gtin.Normalize(code)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[n] {
+			continue
+		}
+
+		seen[n] = true
+		normalized = append(normalized, n)
+	}
+
+	return normalized, nil
+}
+
+func barcodeList(rows []FoodBarcodeDto) []string {
+	codes := make([]string, 0, len(rows))
+	for _, row := range rows {
+		codes = append(codes, row.Code)
+	}
+
+	return codes
+}
+
+func (d *DataConn) getFoodBarcodes(food uuid.UUID) ([]string, error) {
+	var rows []FoodBarcodeDto
+
+	err := d.DB.Model(&rows).Where("fb.food = ?", food).Order("fb.code ASC").Select()
+	if err != nil {
+		return nil, err
+	}
+
+	return barcodeList(rows), nil
+}
+
+// replaceFoodBarcodes swaps the barcodes of a food inside tx. Codes that
+// already belong to another food are reported as a conflict.
+func replaceFoodBarcodes(tx *pg.Tx, food uuid.UUID, codes []string) error {
+	var existing FoodBarcodeDto
+	_, err := tx.Model(&existing).Where("food = ?", food).Delete()
+	if err != nil {
+		return err
+	}
+
+	if len(codes) == 0 {
+		return nil
+	}
+
+	rows := make([]FoodBarcodeDto, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, FoodBarcodeDto{Code: code, Food: food})
+	}
+
+	_, err = tx.Model(&rows).Insert()
+
+	pgErr, ok := err.(pg.Error)
+	if ok && pgErr.Field('C') == "23505" {
+		return conflict("barcode already belongs to another food")
+	}
+
+	return err
+}
+
+// GetFoodByBarcode looks a food up by any UPC, EAN or GTIN spelling of one of
+// its barcodes.
+func (d *DataConn) GetFoodByBarcode(code string) (FoodTableDto, error) {
+	var food FoodTableDto
+
+	normalized, err := gtin.Normalize(code)
+	if err != nil {
+		return food, err
+	}
+
+	err = d.DB.Model(&food).
+		Relation("User").
+		Relation("FoodType").
+		Relation("Brand").
+		Relation("Servings").
+		Relation("Values").
+		Relation("BarcodeRows").
+		Where("f.id = (SELECT fb.food FROM core.food_barcode AS fb WHERE fb.code = ?)", normalized).
+		Apply(alive).
+		Select()
+	if err != nil {
+		return food, err
+	}
+
+	food.Nutrients = nutrientMap(food.Values)
+	food.Barcodes = barcodeList(food.BarcodeRows)
+
+	return food, nil
+}
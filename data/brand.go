@@ -1,10 +1,10 @@
 package data
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
@@ -16,6 +16,8 @@ type BrandDto struct {
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
 	User      uuid.UUID `json:"user" db:"user"`
 	Name      string    `json:"name" db:"name" validate:"min=3"`
+	Version   int       `json:"version" db:"version"`
+	Trash
 }
 
 //lint:ignore U1000 Ignore unused function temporarily for debugging
@@ -26,6 +28,15 @@ type BrandFilterDto struct {
 	Skip uint16    `json:"skip"`
 }
 
+var brandSortColumns = sortColumns{
+	"name":      "b.name",
+	"timestamp": "b.timestamp",
+}
+
+func (b BrandDto) cursor() Cursor {
+	return Cursor{Timestamp: b.Timestamp, Id: b.Id}
+}
+
 func NewBrandDto(user uuid.UUID, name string) (*BrandDto, error) {
 	validate := validator.New()
 
@@ -58,21 +69,34 @@ func NewBrandFilterDto(id uuid.UUID, name string) (*BrandFilterDto, error) {
 	return filter, nil
 }
 
-func (d *DataConn) ListBrands(pageIndex, pageSize int) ([]BrandDto, error) {
+func (f BrandFilterDto) apply(q *orm.Query) (*orm.Query, error) {
+	if f.Id != uuid.Nil {
+		q = q.Where("b.id = ?", f.Id)
+	}
+
+	if f.Name != "" {
+		q = q.Where("b.name ILIKE ?", containsPattern(f.Name))
+	}
+
+	return q, nil
+}
+
+func (d *DataConn) ListBrands(filter BrandFilterDto, page Page) ([]BrandDto, Cursors, error) {
 	var brands []BrandDto
 
-	err := d.DB.Model(&brands).Limit(pageSize).Offset(pageIndex * pageSize).Select()
+	err := d.DB.Model(&brands).Apply(alive).Apply(filter.apply).Apply(page.apply(brandSortColumns)).Select()
 	if err != nil {
-		return nil, err
+		return nil, Cursors{}, err
 	}
 
-	return brands, nil
+	brands, cursors := paginate(brands, page)
+	return brands, cursors, nil
 }
 
-func (d *DataConn) CountBrands() (int, error) {
+func (d *DataConn) CountBrands(filter BrandFilterDto) (int, error) {
 	var brands []BrandDto
 
-	count, err := d.DB.Model(&brands).Count()
+	count, err := d.DB.Model(&brands).Apply(alive).Apply(filter.apply).Count()
 	if err != nil {
 		return 0, err
 	}
@@ -83,7 +107,7 @@ func (d *DataConn) CountBrands() (int, error) {
 func (d *DataConn) GetBrandById(id uuid.UUID) (BrandDto, error) {
 	var brand BrandDto
 
-	err := d.DB.Model(&brand).Where("id = ?", id).Select()
+	err := d.DB.Model(&brand).Where("id = ?", id).Apply(alive).Select()
 
 	if err != nil {
 		return brand, err
@@ -92,25 +116,151 @@ func (d *DataConn) GetBrandById(id uuid.UUID) (BrandDto, error) {
 	return brand, nil
 }
 
-func (d *DataConn) CreateBrand(dto BrandDto) error {
-	_, err := d.DB.Model(&dto).Insert()
+func (d *DataConn) CreateBrand(actor Actor, dto BrandDto) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		_, err := tx.Model(&dto).Insert()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.Field('C') == "23505" {
+			return conflict("name already exists")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return changes.created("brand", dto.Id, dto)
+	})
+}
+
+func (d *DataConn) EditBrand(actor Actor, id uuid.UUID, version int, name string) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := previous[BrandDto](tx, id)
+		if err != nil {
+			return err
+		}
+
+		if before == nil {
+			return pg.ErrNoRows
+		}
+
+		err = actor.mayChange(before.User)
+		if err != nil {
+			return err
+		}
+
+		err = expectVersion(before.Version, version)
+		if err != nil {
+			return err
+		}
+
+		var brand BrandDto
+		res, err := tx.Model(&brand).Set("name = ?", name).Where("id = ?", id).Apply(actor.owns).Returning("*").Update()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.Field('C') == "23505" {
+			return conflict("name already exists")
+		}
+
+		err = ownedResult(tx, &brand, id, res, err)
+		if err != nil {
+			return err
+		}
+
+		return changes.updated("brand", id, before, brand)
+	})
+}
 
-	pgErr, ok := err.(pg.Error)
-	if ok && pgErr.IntegrityViolation() {
-		return fmt.Errorf("name already exists")
+func (d *DataConn) DeleteBrand(actor Actor, id uuid.UUID, version int) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		err := lockVersion(tx.Model((*BrandDto)(nil)).Where("id = ?", id).Apply(actor.owns), version)
+		if err != nil {
+			return err
+		}
+
+		var brand BrandDto
+		res, err := moveToTrash(tx.Model(&brand).Where("id = ?", id).Apply(actor.owns), actor.Id)
+		err = ownedResult(tx, &brand, id, res, err)
+		if err != nil {
+			return err
+		}
+
+		return changes.deleted("brand", id, brand)
+	})
+}
+
+// ListBrandTrash lists the brands in the trash that actor may restore.
+func (d *DataConn) ListBrandTrash(actor Actor, page Page) ([]BrandDto, Cursors, error) {
+	var brands []BrandDto
+
+	err := d.DB.Model(&brands).Apply(trashed).Apply(actor.owns).Apply(page.apply(withDeletedAt(brandSortColumns))).Select()
+	if err != nil {
+		return nil, Cursors{}, err
 	}
 
-	return err
+	brands, cursors := paginate(brands, page)
+	return brands, cursors, nil
 }
 
-func (d *DataConn) EditBrand(id uuid.UUID, name string) error {
-	var brand BrandDto
-	_, err := d.DB.Model(&brand).Set("name = ?", name).Where("id = ?", id).Update()
-	return err
+func (d *DataConn) CountBrandTrash(actor Actor) (int, error) {
+	var brands []BrandDto
+
+	count, err := d.DB.Model(&brands).Apply(trashed).Apply(actor.owns).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (d *DataConn) RestoreBrand(actor Actor, id uuid.UUID) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		var brand BrandDto
+		res, err := restoreFromTrash(tx.Model(&brand).Where("id = ?", id).Apply(actor.owns))
+		err = restoredResult(tx, &brand, id, res, err)
+		if err != nil {
+			return err
+		}
+
+		return changes.restored("brand", id, brand)
+	})
 }
 
-func (d *DataConn) DeleteBrand(id uuid.UUID) error {
+// ExportBrands calls fn for every brand matching filter, oldest first,
+// without loading them all into memory.
+func (d *DataConn) ExportBrands(filter BrandFilterDto, fn func(BrandDto) error) error {
 	var brand BrandDto
-	_, err := d.DB.Model(&brand).Where("id = ?", id).Delete()
-	return err
+	return d.DB.Model(&brand).
+		Apply(alive).
+		Apply(filter.apply).
+		Order("b.timestamp ASC", "b.id ASC").
+		ForEach(fn)
+}
+
+// ImportBrands creates the rows without an id and updates those whose id
+// already exists and that actor may edit, see runImport.
+func (d *DataConn) ImportBrands(actor Actor, rows []ImportRow[BrandDto], report ImportReport) (ImportReport, error) {
+	return runImport(d, actor, rows, report, func(tx *pg.Tx, changes *changeSet, dto BrandDto) error {
+		before, err := previous[BrandDto](tx, dto.Id)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Model(&dto).
+			OnConflict("(id) DO UPDATE").
+			Set("name = EXCLUDED.name").
+			Apply(actor.owns).
+			Returning("*").
+			Insert()
+		err = upsertResult(res, err)
+		if err != nil {
+			return err
+		}
+
+		if before == nil {
+			return changes.created("brand", dto.Id, dto)
+		}
+
+		return changes.updated("brand", dto.Id, before, dto)
+	})
 }
@@ -0,0 +1,175 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/adamelfsborg-code/food/culinary/gtin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// RowError reports why one row of a bulk write was rejected. Rows count from
+// 1 in the order they were sent.
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportRow is a validated row of a bulk import and its position in the input.
+type ImportRow[T any] struct {
+	Row   int
+	Value T
+}
+
+type ImportReport struct {
+	Rows      int        `json:"rows"`
+	Imported  int        `json:"imported"`
+	DryRun    bool       `json:"dryRun"`
+	Committed bool       `json:"committed"`
+	Errors    []RowError `json:"errors"`
+}
+
+// errRollback aborts an import transaction without it being reported as a
+// failure.
+var errRollback = errors.New("rollback")
+
+// runImport writes rows one by one in a single transaction. Each row runs in
+// its own savepoint so a failing row is reported without hiding errors in the
+// rows after it. The transaction is only committed when report holds no errors
+// afterwards, including those of rows that already failed validation, and it
+// is not a dry run.
+func runImport[T any](d *DataConn, actor Actor, rows []ImportRow[T], report ImportReport, write func(tx *pg.Tx, changes *changeSet, value T) error) (ImportReport, error) {
+	err := d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		changes := &changeSet{tx: tx, actor: actor}
+
+		for _, row := range rows {
+			writeErr, err := savepoint(tx, func() error {
+				return write(tx, changes, row.Value)
+			})
+			if err != nil {
+				return err
+			}
+
+			if writeErr != nil {
+				report.Errors = append(report.Errors, RowError{Row: row.Row, Error: writeErr.Error()})
+				continue
+			}
+
+			report.Imported++
+		}
+
+		if report.DryRun || len(report.Errors) > 0 {
+			return errRollback
+		}
+
+		return nil
+	})
+	if err != nil && err != errRollback {
+		return report, fmt.Errorf("import failed: %w", err)
+	}
+
+	sort.SliceStable(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Row < report.Errors[j].Row
+	})
+
+	report.Committed = err == nil
+	if report.Committed {
+		d.outboxReady()
+	}
+
+	return report, nil
+}
+
+// savepoint runs write inside a savepoint of tx, so a failing write is undone
+// without aborting tx. writeErr is what write returned; err is only set when
+// tx itself failed and has to be abandoned.
+func savepoint(tx *pg.Tx, write func() error) (writeErr error, err error) {
+	_, err = tx.Exec("SAVEPOINT bulk_item")
+	if err != nil {
+		return nil, err
+	}
+
+	writeErr = write()
+	if writeErr != nil {
+		_, err = tx.Exec("ROLLBACK TO SAVEPOINT bulk_item")
+		return writeErr, err
+	}
+
+	_, err = tx.Exec("RELEASE SAVEPOINT bulk_item")
+	return nil, err
+}
+
+// upsertResult checks an insert that updates the row with the same id when
+// Actor.owns allows it. No row written means the existing row belongs to
+// someone else; duplicate names are a conflict, as for single row creates.
+func upsertResult(res orm.Result, err error) error {
+	pgErr, ok := err.(pg.Error)
+	if ok && pgErr.Field('C') == "23505" {
+		return conflict("name already exists")
+	}
+
+	if err == pg.ErrNoRows || (err == nil && res.RowsAffected() == 0) {
+		return ErrForbidden
+	}
+
+	return err
+}
+
+const (
+	// BulkAtomic commits a bulk write only when every item succeeds.
+	BulkAtomic = "atomic"
+	// BulkBestEffort commits the items that succeed and reports the rest.
+	BulkBestEffort = "best-effort"
+)
+
+// BulkItem is an item of a bulk write and its index in the request.
+type BulkItem[T any] struct {
+	Index int
+	Value T
+}
+
+type BulkItemResult struct {
+	Index int       `json:"index"`
+	Id    uuid.UUID `json:"id"`
+}
+
+type BulkItemError struct {
+	Index int    `json:"index"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+type BulkResult struct {
+	Mode      string           `json:"mode"`
+	Committed bool             `json:"committed"`
+	Created   []BulkItemResult `json:"created"`
+	Updated   []BulkItemResult `json:"updated"`
+	Errors    []BulkItemError  `json:"errors"`
+}
+
+// NewBulkItemError classifies err so clients can tell a duplicate from a
+// missing row or a validation failure without matching on the message.
+func NewBulkItemError(index int, err error) BulkItemError {
+	code := "error"
+
+	var validationErrs validator.ValidationErrors
+	switch {
+	case errors.Is(err, ErrConflict):
+		code = "conflict"
+	case errors.Is(err, pg.ErrNoRows):
+		code = "not_found"
+	case errors.Is(err, ErrForbidden):
+		code = "forbidden"
+	case errors.Is(err, ErrPreconditionFailed):
+		code = "precondition_failed"
+	case errors.As(err, &validationErrs), errors.Is(err, gtin.ErrInvalid), errors.Is(err, gtin.ErrChecksum):
+		code = "invalid"
+	}
+
+	return BulkItemError{Index: index, Code: code, Error: err.Error()}
+}
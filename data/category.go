@@ -1,10 +1,10 @@
 package data
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
@@ -16,6 +16,8 @@ type CategoryDto struct {
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
 	User      uuid.UUID `json:"user" db:"user"`
 	Name      string    `json:"name" db:"name" validate:"min=3"`
+	Version   int       `json:"version" db:"version"`
+	Trash
 }
 
 //lint:ignore U1000 Ignore unused function temporarily for debugging
@@ -26,6 +28,15 @@ type CategoryFilterDto struct {
 	Skip uint16    `json:"skip"`
 }
 
+var categorySortColumns = sortColumns{
+	"name":      "c.name",
+	"timestamp": "c.timestamp",
+}
+
+func (c CategoryDto) cursor() Cursor {
+	return Cursor{Timestamp: c.Timestamp, Id: c.Id}
+}
+
 func NewCategoryDto(user uuid.UUID, name string) (*CategoryDto, error) {
 	validate := validator.New()
 
@@ -58,21 +69,34 @@ func NewCategoryFilterDto(id uuid.UUID, name string) (*CategoryFilterDto, error)
 	return filter, nil
 }
 
-func (d *DataConn) ListCategories(pageIndex, pageSize int) ([]CategoryDto, error) {
+func (f CategoryFilterDto) apply(q *orm.Query) (*orm.Query, error) {
+	if f.Id != uuid.Nil {
+		q = q.Where("c.id = ?", f.Id)
+	}
+
+	if f.Name != "" {
+		q = q.Where("c.name ILIKE ?", containsPattern(f.Name))
+	}
+
+	return q, nil
+}
+
+func (d *DataConn) ListCategories(filter CategoryFilterDto, page Page) ([]CategoryDto, Cursors, error) {
 	var categories []CategoryDto
 
-	err := d.DB.Model(&categories).Limit(pageSize).Offset(pageIndex * pageSize).Select()
+	err := d.DB.Model(&categories).Apply(alive).Apply(filter.apply).Apply(page.apply(categorySortColumns)).Select()
 	if err != nil {
-		return nil, err
+		return nil, Cursors{}, err
 	}
 
-	return categories, nil
+	categories, cursors := paginate(categories, page)
+	return categories, cursors, nil
 }
 
-func (d *DataConn) CountCategories() (int, error) {
+func (d *DataConn) CountCategories(filter CategoryFilterDto) (int, error) {
 	var categories []CategoryDto
 
-	count, err := d.DB.Model(&categories).Count()
+	count, err := d.DB.Model(&categories).Apply(alive).Apply(filter.apply).Count()
 	if err != nil {
 		return 0, err
 	}
@@ -83,7 +107,7 @@ func (d *DataConn) CountCategories() (int, error) {
 func (d *DataConn) GetCategoryById(id uuid.UUID) (CategoryDto, error) {
 	var category CategoryDto
 
-	err := d.DB.Model(&category).Where("id = ?", id).Select()
+	err := d.DB.Model(&category).Where("id = ?", id).Apply(alive).Select()
 
 	if err != nil {
 		return category, err
@@ -92,25 +116,178 @@ func (d *DataConn) GetCategoryById(id uuid.UUID) (CategoryDto, error) {
 	return category, nil
 }
 
-func (d *DataConn) CreateCategory(dto CategoryDto) error {
-	_, err := d.DB.Model(&dto).Insert()
+func (d *DataConn) CreateCategory(actor Actor, dto CategoryDto) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		_, err := tx.Model(&dto).Insert()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.Field('C') == "23505" {
+			return conflict("name already exists")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return changes.created("category", dto.Id, dto)
+	})
+}
+
+// EditCategory renames a category. Categories are a taxonomy shared by every
+// user, so ownership does not apply: only curators may change them.
+func (d *DataConn) EditCategory(actor Actor, id uuid.UUID, version int, name string) error {
+	if !actor.Can(PermTaxonomyWrite) {
+		return ErrForbidden
+	}
+
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := previous[CategoryDto](tx, id)
+		if err != nil {
+			return err
+		}
+
+		if before == nil {
+			return pg.ErrNoRows
+		}
+
+		err = expectVersion(before.Version, version)
+		if err != nil {
+			return err
+		}
+
+		var category CategoryDto
+		_, err = tx.Model(&category).Set("name = ?", name).Where("id = ?", id).Returning("*").Update()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.Field('C') == "23505" {
+			return conflict("name already exists")
+		}
+
+		if err != nil {
+			return err
+		}
 
-	pgErr, ok := err.(pg.Error)
-	if ok && pgErr.IntegrityViolation() {
-		return fmt.Errorf("name already exists")
+		return changes.updated("category", id, before, category)
+	})
+}
+
+// DeleteCategory moves a category to the trash, see EditCategory.
+func (d *DataConn) DeleteCategory(actor Actor, id uuid.UUID, version int) error {
+	if !actor.Can(PermTaxonomyWrite) {
+		return ErrForbidden
 	}
 
-	return err
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		err := lockVersion(tx.Model((*CategoryDto)(nil)).Where("id = ?", id), version)
+		if err != nil {
+			return err
+		}
+
+		var category CategoryDto
+		res, err := moveToTrash(tx.Model(&category).Where("id = ?", id), actor.Id)
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return changes.deleted("category", id, category)
+	})
 }
 
-func (d *DataConn) EditCategory(id uuid.UUID, name string) error {
-	var category CategoryDto
-	_, err := d.DB.Model(&category).Set("name = ?", name).Where("id = ?", id).Update()
-	return err
+// ListCategoryTrash lists the categories in the trash, see EditCategory.
+func (d *DataConn) ListCategoryTrash(actor Actor, page Page) ([]CategoryDto, Cursors, error) {
+	if !actor.Can(PermTaxonomyWrite) {
+		return nil, Cursors{}, ErrForbidden
+	}
+
+	var categories []CategoryDto
+
+	err := d.DB.Model(&categories).Apply(trashed).Apply(page.apply(withDeletedAt(categorySortColumns))).Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	categories, cursors := paginate(categories, page)
+	return categories, cursors, nil
+}
+
+func (d *DataConn) CountCategoryTrash(actor Actor) (int, error) {
+	if !actor.Can(PermTaxonomyWrite) {
+		return 0, ErrForbidden
+	}
+
+	var categories []CategoryDto
+
+	count, err := d.DB.Model(&categories).Apply(trashed).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RestoreCategory takes a category out of the trash, see EditCategory.
+func (d *DataConn) RestoreCategory(actor Actor, id uuid.UUID) error {
+	if !actor.Can(PermTaxonomyWrite) {
+		return ErrForbidden
+	}
+
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		var category CategoryDto
+		res, err := restoreFromTrash(tx.Model(&category).Where("id = ?", id))
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return changes.restored("category", id, category)
+	})
 }
 
-func (d *DataConn) DeleteCategory(id uuid.UUID) error {
+// ExportCategories calls fn for every category matching filter, oldest first,
+// without loading them all into memory.
+func (d *DataConn) ExportCategories(filter CategoryFilterDto, fn func(CategoryDto) error) error {
 	var category CategoryDto
-	_, err := d.DB.Model(&category).Where("id = ?", id).Delete()
-	return err
+	return d.DB.Model(&category).
+		Apply(alive).
+		Apply(filter.apply).
+		Order("c.timestamp ASC", "c.id ASC").
+		ForEach(fn)
+}
+
+// ImportCategories creates the rows without an id and updates those whose id
+// already exists, see runImport. Like EditCategory it is limited to curators.
+func (d *DataConn) ImportCategories(actor Actor, rows []ImportRow[CategoryDto], report ImportReport) (ImportReport, error) {
+	if !actor.Can(PermTaxonomyWrite) {
+		return report, ErrForbidden
+	}
+
+	return runImport(d, actor, rows, report, func(tx *pg.Tx, changes *changeSet, dto CategoryDto) error {
+		before, err := previous[CategoryDto](tx, dto.Id)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Model(&dto).
+			OnConflict("(id) DO UPDATE").
+			Set("name = EXCLUDED.name").
+			Returning("*").
+			Insert()
+		err = upsertResult(res, err)
+		if err != nil {
+			return err
+		}
+
+		if before == nil {
+			return changes.created("category", dto.Id, dto)
+		}
+
+		return changes.updated("category", dto.Id, before, dto)
+	})
 }
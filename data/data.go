@@ -1,6 +1,8 @@
 package data
 
 import (
+	"strings"
+
 	"github.com/adamelfsborg-code/food/culinary/config"
 	"github.com/go-pg/pg/v10"
 	"github.com/nats-io/nats.go"
@@ -11,6 +13,17 @@ type DataConn struct {
 	DB   pg.DB
 	Nats *nats.Conn
 	JS   nats.JetStreamContext
+	// OutboxReady is signalled after a write added events to the outbox, see
+	// RelayOutbox. It may be nil.
+	OutboxReady chan struct{}
 }
 
 var Data *DataConn
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern builds an ILIKE pattern matching value anywhere in a column,
+// escaping the wildcard characters a client might send.
+func containsPattern(value string) string {
+	return "%" + likeEscaper.Replace(value) + "%"
+}
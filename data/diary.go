@@ -0,0 +1,318 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type DiaryEntryDto struct {
+	tableName struct{}  `pg:"core.diary_entry,alias:de"`
+	Id        uuid.UUID `json:"id" db:"id"`
+	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+	User      uuid.UUID `json:"user" db:"user"`
+	Food      uuid.UUID `json:"food" db:"food" validate:"required"`
+	Grams     float32   `json:"grams" db:"grams" validate:"gt=0"`
+	Meal      string    `json:"meal" db:"meal" validate:"oneof=breakfast lunch dinner snack"`
+	EatenAt   time.Time `json:"eatenAt" db:"eaten_at" validate:"required"`
+	Version   int       `json:"version" db:"version"`
+	Trash
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type DiaryEntryTableDto struct {
+	tableName struct{}     `pg:"core.diary_entry,alias:de"`
+	Id        uuid.UUID    `json:"id" pg:"id"`
+	Timestamp time.Time    `json:"timestamp" pg:"timestamp"`
+	UserId    uuid.UUID    `json:"user" pg:"user"`
+	FoodId    uuid.UUID    `json:"-" pg:"food"`
+	Food      *FoodDto     `json:"food" pg:"fk:food,rel:has-one"`
+	Grams     float32      `json:"grams" pg:"grams"`
+	Meal      string       `json:"meal" pg:"meal"`
+	EatenAt   time.Time    `json:"eatenAt" pg:"eaten_at"`
+	Nutrition NutritionDto `json:"nutrition" pg:"-"`
+	Version   int          `json:"version" pg:"version"`
+	Trash
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type DiaryFilterDto struct {
+	User uuid.UUID `json:"user" db:"user" validate:"required"`
+	Meal string    `json:"meal" db:"meal" validate:"omitempty,oneof=breakfast lunch dinner snack"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// DiaryDayDto is one calendar day of a user's diary in the time zone it was
+// requested in.
+type DiaryDayDto struct {
+	Date     string                  `json:"date"`
+	TimeZone string                  `json:"timeZone"`
+	Entries  []DiaryEntryTableDto    `json:"entries"`
+	Meals    map[string]NutritionDto `json:"meals"`
+	Total    NutritionDto            `json:"total"`
+}
+
+var diarySortColumns = sortColumns{
+	"timestamp": "de.timestamp",
+	"eatenAt":   "de.eaten_at",
+	"grams":     "de.grams",
+	"meal":      "de.meal",
+}
+
+func (e DiaryEntryTableDto) cursor() Cursor {
+	return Cursor{Timestamp: e.Timestamp, Id: e.Id}
+}
+
+func (e *DiaryEntryTableDto) computeNutrition() {
+	if e.Food != nil {
+		e.Nutrition = e.Food.Nutrition().ForGrams(e.Grams)
+	}
+}
+
+func NewDiaryEntry(user, food uuid.UUID, grams float32, meal string, eatenAt time.Time) (*DiaryEntryDto, error) {
+	validate := validator.New()
+
+	entry := &DiaryEntryDto{
+		User:    user,
+		Food:    food,
+		Grams:   grams,
+		Meal:    meal,
+		EatenAt: eatenAt,
+	}
+
+	err := validate.Struct(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+func NewDiaryFilterDto(user uuid.UUID, meal string, from, to time.Time) (*DiaryFilterDto, error) {
+	validate := validator.New()
+
+	filter := &DiaryFilterDto{
+		User: user,
+		Meal: meal,
+		From: from,
+		To:   to,
+	}
+
+	err := validate.Struct(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func (f DiaryFilterDto) apply(q *orm.Query) (*orm.Query, error) {
+	q = q.Where(`de."user" = ?`, f.User)
+
+	if f.Meal != "" {
+		q = q.Where("de.meal = ?", f.Meal)
+	}
+
+	if !f.From.IsZero() {
+		q = q.Where("de.eaten_at >= ?", f.From)
+	}
+
+	if !f.To.IsZero() {
+		q = q.Where("de.eaten_at < ?", f.To)
+	}
+
+	return q, nil
+}
+
+func (d *DataConn) ListDiaryEntries(filter DiaryFilterDto, page Page) ([]DiaryEntryTableDto, Cursors, error) {
+	var entries []DiaryEntryTableDto
+
+	err := d.DB.Model(&entries).
+		Relation("Food").
+		Apply(alive).
+		Apply(filter.apply).
+		Apply(page.apply(diarySortColumns)).
+		Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	entries, cursors := paginate(entries, page)
+	for i := range entries {
+		entries[i].computeNutrition()
+	}
+
+	return entries, cursors, nil
+}
+
+func (d *DataConn) CountDiaryEntries(filter DiaryFilterDto) (int, error) {
+	var entries []DiaryEntryDto
+
+	count, err := d.DB.Model(&entries).Apply(alive).Apply(filter.apply).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (d *DataConn) GetDiaryEntryById(user, id uuid.UUID) (DiaryEntryTableDto, error) {
+	var entry DiaryEntryTableDto
+
+	err := d.DB.Model(&entry).
+		Relation("Food").
+		Where("de.id = ?", id).
+		Where(`de."user" = ?`, user).
+		Apply(alive).
+		Select()
+
+	if err != nil {
+		return entry, err
+	}
+
+	entry.computeNutrition()
+	return entry, nil
+}
+
+// GetDiaryDay returns the entries eaten on date in loc, so a day always runs
+// from local midnight to local midnight regardless of DST changes.
+func (d *DataConn) GetDiaryDay(user uuid.UUID, date time.Time, loc *time.Location) (DiaryDayDto, error) {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+
+	day := DiaryDayDto{
+		Date:     start.Format(time.DateOnly),
+		TimeZone: loc.String(),
+		Entries:  []DiaryEntryTableDto{},
+		Meals:    map[string]NutritionDto{},
+	}
+
+	err := d.DB.Model(&day.Entries).
+		Relation("Food").
+		Where(`de."user" = ?`, user).
+		Where("de.eaten_at >= ?", start).
+		Where("de.eaten_at < ?", end).
+		Apply(alive).
+		Order("de.eaten_at ASC", "de.id ASC").
+		Select()
+	if err != nil {
+		return day, err
+	}
+
+	for i := range day.Entries {
+		entry := &day.Entries[i]
+		entry.computeNutrition()
+
+		day.Meals[entry.Meal] = day.Meals[entry.Meal].Add(entry.Nutrition)
+		day.Total = day.Total.Add(entry.Nutrition)
+	}
+
+	return day, nil
+}
+
+func (d *DataConn) CreateDiaryEntry(dto DiaryEntryDto) error {
+	_, err := d.DB.Model(&dto).Insert()
+	return err
+}
+
+func (d *DataConn) EditDiaryEntry(user, id uuid.UUID, version int, food uuid.UUID, grams float32, meal string, eatenAt time.Time) error {
+	return d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		err := lockVersion(tx.Model((*DiaryEntryDto)(nil)).Where("id = ?", id).Where(`"user" = ?`, user), version)
+		if err != nil {
+			return err
+		}
+
+		var entry DiaryEntryDto
+		res, err := tx.Model(&entry).
+			Set("food = ?", food).
+			Set("grams = ?", grams).
+			Set("meal = ?", meal).
+			Set("eaten_at = ?", eatenAt).
+			Where("id = ?", id).
+			Where(`"user" = ?`, user).
+			Apply(alive).
+			Update()
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return nil
+	})
+}
+
+func (d *DataConn) DeleteDiaryEntry(user, id uuid.UUID, version int) error {
+	return d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		err := lockVersion(tx.Model((*DiaryEntryDto)(nil)).Where("id = ?", id).Where(`"user" = ?`, user), version)
+		if err != nil {
+			return err
+		}
+
+		var entry DiaryEntryDto
+		res, err := moveToTrash(tx.Model(&entry).Where("id = ?", id).Where(`"user" = ?`, user), user)
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return nil
+	})
+}
+
+func (d *DataConn) ListDiaryTrash(user uuid.UUID, page Page) ([]DiaryEntryTableDto, Cursors, error) {
+	var entries []DiaryEntryTableDto
+
+	err := d.DB.Model(&entries).
+		Relation("Food").
+		Where(`de."user" = ?`, user).
+		Apply(trashed).
+		Apply(page.apply(withDeletedAt(diarySortColumns))).
+		Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	entries, cursors := paginate(entries, page)
+	for i := range entries {
+		entries[i].computeNutrition()
+	}
+
+	return entries, cursors, nil
+}
+
+func (d *DataConn) CountDiaryTrash(user uuid.UUID) (int, error) {
+	var entries []DiaryEntryDto
+
+	count, err := d.DB.Model(&entries).Where(`de."user" = ?`, user).Apply(trashed).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (d *DataConn) RestoreDiaryEntry(user, id uuid.UUID) error {
+	var entry DiaryEntryDto
+	res, err := restoreFromTrash(d.DB.Model(&entry).Where("id = ?", id).Where(`"user" = ?`, user))
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConflict is matched by errors.Is for writes rejected by a uniqueness
+// constraint.
+var ErrConflict = errors.New("conflict")
+
+type conflictError struct {
+	message string
+}
+
+func (e conflictError) Error() string {
+	return e.message
+}
+
+func (e conflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// conflict formats a message like fmt.Errorf and marks it as an ErrConflict
+// without changing the text returned to clients.
+func conflict(format string, args ...any) error {
+	return conflictError{message: fmt.Sprintf(format, args...)}
+}
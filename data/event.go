@@ -0,0 +1,96 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adamelfsborg-code/food/culinary/events"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+// changeSet records the events of a write in the outbox and the audit log of
+// its transaction, so they are published exactly when the write commits. A
+// savepoint that is rolled back takes its events with it.
+type changeSet struct {
+	tx    *pg.Tx
+	actor Actor
+}
+
+func (c *changeSet) record(entity, action string, id any, before, after any) error {
+	event, err := events.New(entity, action, fmt.Sprint(id), c.actor.Id, before, after)
+	if err != nil {
+		return err
+	}
+
+	err = addAudit(c.tx, event, c.actor.RequestId)
+	if err != nil {
+		return err
+	}
+
+	return addOutbox(c.tx, event)
+}
+
+func (c *changeSet) created(entity string, id any, after any) error {
+	return c.record(entity, events.Created, id, nil, after)
+}
+
+func (c *changeSet) updated(entity string, id any, before, after any) error {
+	return c.record(entity, events.Updated, id, before, after)
+}
+
+func (c *changeSet) deleted(entity string, id any, before any) error {
+	return c.record(entity, events.Deleted, id, before, nil)
+}
+
+func (c *changeSet) restored(entity string, id any, after any) error {
+	return c.record(entity, events.Restored, id, nil, after)
+}
+
+// write runs fn in a transaction on behalf of actor, recording its events in
+// the outbox and the audit log of the same transaction.
+func (d *DataConn) write(actor Actor, fn func(tx *pg.Tx, changes *changeSet) error) error {
+	err := d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		return fn(tx, &changeSet{tx: tx, actor: actor})
+	})
+	if err != nil {
+		return err
+	}
+
+	d.outboxReady()
+	return nil
+}
+
+// outboxReady wakes the relay without waiting for it.
+func (d *DataConn) outboxReady() {
+	select {
+	case d.OutboxReady <- struct{}{}:
+	default:
+	}
+}
+
+// previous locks the row of T with id for the rest of tx and returns it, or
+// nil when there is none, so an upsert can report what it replaced. A row in
+// the trash is a conflict: it has to be restored before it can be changed.
+func previous[T any](tx *pg.Tx, id uuid.UUID) (*T, error) {
+	var row T
+
+	if id == uuid.Nil {
+		return nil, nil
+	}
+
+	err := tx.Model(&row).Where("?TableAlias.id = ?", id).For("UPDATE").Select()
+	if err == pg.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := any(row).(interface{ InTrash() bool }); ok && t.InTrash() {
+		return nil, trashConflict(id)
+	}
+
+	return &row, nil
+}
@@ -1,67 +1,114 @@
 package data
 
 import (
-	"fmt"
+	"context"
+	"sort"
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
 //lint:ignore U1000 Ignore unused function temporarily for debugging
 type FoodDto struct {
-	tableName   struct{}  `pg:"core.food,alias:f"`
-	Id          uuid.UUID `json:"id" db:"id"`
-	Timestamp   time.Time `json:"timestamp" db:"timestamp"`
-	User        uuid.UUID `json:"user" db:"user"`
-	FoodType    uuid.UUID `json:"foodtype" db:"food_type"`
-	Brand       uuid.UUID `json:"brand" db:"brand"`
-	Name        string    `json:"name" db:"name" validate:"min=3"`
-	KCAL        float32   `json:"kcal" db:"kcal"`
-	Protein     float32   `json:"protein" db:"protein"`
-	Carbs       float32   `json:"carbs" db:"carbs"`
-	Fat         float32   `json:"fat" db:"fat"`
-	Saturated   float32   `json:"saturated" db:"saturated"`
-	Unsaturated float32   `json:"unsaturated" db:"unsaturated"`
-	Fiber       float32   `json:"fiber" db:"fiber"`
-	Sugars      float32   `json:"sugars" db:"sugars"`
+	tableName   struct{}           `pg:"core.food,alias:f"`
+	Id          uuid.UUID          `json:"id" db:"id"`
+	Timestamp   time.Time          `json:"timestamp" db:"timestamp"`
+	User        uuid.UUID          `json:"user" db:"user"`
+	FoodType    uuid.UUID          `json:"foodtype" db:"food_type"`
+	Brand       uuid.UUID          `json:"brand" db:"brand"`
+	Name        string             `json:"name" db:"name" validate:"min=3"`
+	KCAL        float32            `json:"kcal" db:"kcal"`
+	Protein     float32            `json:"protein" db:"protein"`
+	Carbs       float32            `json:"carbs" db:"carbs"`
+	Fat         float32            `json:"fat" db:"fat"`
+	Saturated   float32            `json:"saturated" db:"saturated"`
+	Unsaturated float32            `json:"unsaturated" db:"unsaturated"`
+	Fiber       float32            `json:"fiber" db:"fiber"`
+	Sugars      float32            `json:"sugars" db:"sugars"`
+	Nutrients   map[string]float32 `json:"nutrients,omitempty" pg:"-" validate:"dive,keys,min=1,endkeys,gte=0"`
+	Barcodes    []string           `json:"barcodes,omitempty" pg:"-"`
+	Version     int                `json:"version" db:"version"`
+	Trash
 }
 
 //lint:ignore U1000 Ignore unused function temporarily for debugging
 type FoodTableDto struct {
-	tableName   struct{}     `pg:"core.food,alias:f"`
-	Id          uuid.UUID    `json:"id" pg:"id"`
-	Timestamp   time.Time    `json:"timestamp" pg:"timestamp"`
-	UserId      uuid.UUID    `json:"-" pg:"user"`
-	FoodTypeId  uuid.UUID    `json:"-" pg:"food_type"`
-	BrandId     uuid.UUID    `json:"-" pg:"brand"`
-	User        *AuthDto     `json:"user" pg:"fk:user,rel:has-one"`
-	FoodType    *FoodTypeDto `json:"foodtype" pg:"fk:food_type,rel:has-one"`
-	Brand       *BrandDto    `json:"brand" pg:"fk:brand,rel:has-one"`
-	Name        string       `json:"name" pg:"name" validate:"min=3"`
-	KCAL        float32      `json:"kcal" pg:"kcal"`
-	Protein     float32      `json:"protein" pg:"protein"`
-	Carbs       float32      `json:"carbs" pg:"carbs"`
-	Fat         float32      `json:"fat" pg:"fat"`
-	Saturated   float32      `json:"saturated" pg:"saturated"`
-	Unsaturated float32      `json:"unsaturated" pg:"unsaturated"`
-	Fiber       float32      `json:"fiber" pg:"fiber"`
-	Sugars      float32      `json:"sugars" pg:"sugars"`
+	tableName   struct{}           `pg:"core.food,alias:f"`
+	Id          uuid.UUID          `json:"id" pg:"id"`
+	Timestamp   time.Time          `json:"timestamp" pg:"timestamp"`
+	UserId      uuid.UUID          `json:"-" pg:"user"`
+	FoodTypeId  uuid.UUID          `json:"-" pg:"food_type"`
+	BrandId     uuid.UUID          `json:"-" pg:"brand"`
+	User        *AuthDto           `json:"user" pg:"fk:user,rel:has-one"`
+	FoodType    *FoodTypeDto       `json:"foodtype" pg:"fk:food_type,rel:has-one"`
+	Brand       *BrandDto          `json:"brand" pg:"fk:brand,rel:has-one"`
+	Servings    []FoodServingDto   `json:"servings" pg:"rel:has-many,join_fk:food"`
+	Name        string             `json:"name" pg:"name" validate:"min=3"`
+	KCAL        float32            `json:"kcal" pg:"kcal"`
+	Protein     float32            `json:"protein" pg:"protein"`
+	Carbs       float32            `json:"carbs" pg:"carbs"`
+	Fat         float32            `json:"fat" pg:"fat"`
+	Saturated   float32            `json:"saturated" pg:"saturated"`
+	Unsaturated float32            `json:"unsaturated" pg:"unsaturated"`
+	Fiber       float32            `json:"fiber" pg:"fiber"`
+	Sugars      float32            `json:"sugars" pg:"sugars"`
+	Values      []FoodNutrientDto  `json:"-" pg:"rel:has-many,join_fk:food"`
+	Nutrients   map[string]float32 `json:"nutrients" pg:"-"`
+	BarcodeRows []FoodBarcodeDto   `json:"-" pg:"rel:has-many,join_fk:food"`
+	Barcodes    []string           `json:"barcodes" pg:"-"`
+	Version     int                `json:"version" pg:"version"`
+	Trash
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type FoodSearchDto struct {
+	tableName struct{} `pg:"core.food,alias:f"`
+	FoodTableDto
+	Score float32 `json:"score" pg:"score"`
 }
 
 //lint:ignore U1000 Ignore unused function temporarily for debugging
 type FoodFilterDto struct {
 	Id       uuid.UUID `json:"id" db:"id"`
 	Name     string    `json:"name" db:"name"`
+	Brand    uuid.UUID `json:"brand" db:"brand"`
+	FoodType uuid.UUID `json:"foodtype" db:"food_type"`
 	Category uuid.UUID `json:"category" db:"category"`
 	Take     uint16    `json:"take"`
 	Skip     uint16    `json:"skip"`
 }
 
-func NewFood(name string, kcal float32, protein float32, carbs float32, fat float32, saturated float32, unstaturated float32, fiber float32, sugars float32, user, foodType, brand uuid.UUID) (*FoodDto, error) {
+var foodSortColumns = sortColumns{
+	"name":        "f.name",
+	"timestamp":   "f.timestamp",
+	"kcal":        "f.kcal",
+	"protein":     "f.protein",
+	"carbs":       "f.carbs",
+	"fat":         "f.fat",
+	"saturated":   "f.saturated",
+	"unsaturated": "f.unsaturated",
+	"fiber":       "f.fiber",
+	"sugars":      "f.sugars",
+	"brand":       `"brand".name`,
+	"foodtype":    `"food_type".name`,
+	"category":    `(SELECT c.name FROM core.category AS c WHERE c.id = "food_type".category)`,
+}
+
+func (f FoodTableDto) cursor() Cursor {
+	return Cursor{Timestamp: f.Timestamp, Id: f.Id}
+}
+
+func NewFood(name string, kcal float32, protein float32, carbs float32, fat float32, saturated float32, unstaturated float32, fiber float32, sugars float32, nutrients map[string]float32, barcodes []string, user, foodType, brand uuid.UUID) (*FoodDto, error) {
 	validate := validator.New()
 
+	barcodes, err := normalizeBarcodes(barcodes)
+	if err != nil {
+		return nil, err
+	}
+
 	food := &FoodDto{
 		User:        user,
 		Name:        name,
@@ -75,9 +122,11 @@ func NewFood(name string, kcal float32, protein float32, carbs float32, fat floa
 		Unsaturated: unstaturated,
 		Fiber:       fiber,
 		Sugars:      sugars,
+		Nutrients:   nutrients,
+		Barcodes:    barcodes,
 	}
 
-	err := validate.Struct(food)
+	err = validate.Struct(food)
 	if err != nil {
 		return nil, err
 	}
@@ -85,12 +134,15 @@ func NewFood(name string, kcal float32, protein float32, carbs float32, fat floa
 	return food, nil
 }
 
-func NewFoodFilterDto(id uuid.UUID, name string) (*FoodFilterDto, error) {
+func NewFoodFilterDto(id uuid.UUID, name string, brand, foodType, category uuid.UUID) (*FoodFilterDto, error) {
 	validate := validator.New()
 
 	filter := &FoodFilterDto{
-		Id:   id,
-		Name: name,
+		Id:       id,
+		Name:     name,
+		Brand:    brand,
+		FoodType: foodType,
+		Category: category,
 	}
 
 	err := validate.Struct(filter)
@@ -101,30 +153,66 @@ func NewFoodFilterDto(id uuid.UUID, name string) (*FoodFilterDto, error) {
 	return filter, nil
 }
 
-func (d *DataConn) ListFoods(pageIndex, pageSize int) ([]FoodTableDto, error) {
+func (f FoodFilterDto) apply(q *orm.Query) (*orm.Query, error) {
+	if f.Id != uuid.Nil {
+		q = q.Where("f.id = ?", f.Id)
+	}
+
+	if f.Name != "" {
+		q = q.Where("f.name ILIKE ?", containsPattern(f.Name))
+	}
+
+	if f.Brand != uuid.Nil {
+		q = q.Where("f.brand = ?", f.Brand)
+	}
+
+	if f.FoodType != uuid.Nil {
+		q = q.Where("f.food_type = ?", f.FoodType)
+	}
+
+	if f.Category != uuid.Nil {
+		q = q.Where("f.food_type IN (SELECT ft.id FROM core.food_type AS ft WHERE ft.category = ?)", f.Category)
+	}
+
+	return q, nil
+}
+
+func (d *DataConn) ListFoods(filter FoodFilterDto, page Page) ([]FoodTableDto, Cursors, error) {
 	var foods []FoodTableDto
 
 	err := d.DB.Model(&foods).
 		Relation("User").
 		Relation("FoodType").
 		Relation("Brand").
-		Limit(pageSize).
-		Offset(pageSize * pageIndex).
+		Relation("Servings").
+		Relation("Values").
+		Relation("BarcodeRows").
+		Apply(alive).
+		Apply(filter.apply).
+		Apply(page.apply(foodSortColumns)).
 		Select()
 	if err != nil {
-		return nil, err
+		return nil, Cursors{}, err
 	}
 
-	return foods, nil
+	foods, cursors := paginate(foods, page)
+	for i := range foods {
+		foods[i].Nutrients = nutrientMap(foods[i].Values)
+		foods[i].Barcodes = barcodeList(foods[i].BarcodeRows)
+	}
+
+	return foods, cursors, nil
 }
 
-func (d *DataConn) CountFoods() (int, error) {
+func (d *DataConn) CountFoods(filter FoodFilterDto) (int, error) {
 	var foods []FoodTableDto
 
 	count, err := d.DB.Model(&foods).
 		Relation("User").
 		Relation("FoodType").
 		Relation("Brand").
+		Apply(alive).
+		Apply(filter.apply).
 		Count()
 
 	if err != nil {
@@ -134,50 +222,523 @@ func (d *DataConn) CountFoods() (int, error) {
 	return count, nil
 }
 
+// foodSearchMatch and foodSearchScore rank foods against the search term in ?0.
+// Both rely on the trigram and tsvector indexes in db/migrations/0002_food_search.up.sql.
+const foodSearchMatch = `(
+	core.immutable_unaccent(?0) <% core.immutable_unaccent(f.name) OR
+	core.immutable_unaccent(?0) <% core.immutable_unaccent("brand".name) OR
+	core.immutable_unaccent(?0) <% core.immutable_unaccent("food_type".name) OR
+	to_tsvector('simple', core.immutable_unaccent(f.name)) @@ plainto_tsquery('simple', core.immutable_unaccent(?0))
+)`
+
+const foodSearchScore = `greatest(
+	word_similarity(core.immutable_unaccent(?0), core.immutable_unaccent(f.name)),
+	word_similarity(core.immutable_unaccent(?0), core.immutable_unaccent(coalesce("brand".name, ''))) * 0.6,
+	word_similarity(core.immutable_unaccent(?0), core.immutable_unaccent(coalesce("food_type".name, ''))) * 0.6
+) + ts_rank(
+	setweight(to_tsvector('simple', core.immutable_unaccent(f.name)), 'A') ||
+	setweight(to_tsvector('simple', core.immutable_unaccent(coalesce("brand".name, ''))), 'B') ||
+	setweight(to_tsvector('simple', core.immutable_unaccent(coalesce("food_type".name, ''))), 'B'),
+	plainto_tsquery('simple', core.immutable_unaccent(?0))
+) AS score`
+
+func (d *DataConn) SearchFoods(term string, limit int) ([]FoodSearchDto, error) {
+	var foods []FoodSearchDto
+
+	err := d.DB.Model(&foods).
+		ColumnExpr("f.*").
+		ColumnExpr(foodSearchScore, term).
+		Relation("User").
+		Relation("FoodType").
+		Relation("Brand").
+		Relation("Servings").
+		Relation("Values").
+		Relation("BarcodeRows").
+		Where(foodSearchMatch, term).
+		Apply(alive).
+		OrderExpr("score DESC").
+		OrderExpr("f.id").
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range foods {
+		foods[i].Nutrients = nutrientMap(foods[i].Values)
+		foods[i].Barcodes = barcodeList(foods[i].BarcodeRows)
+	}
+
+	return foods, nil
+}
+
 func (d *DataConn) GetFoodById(id uuid.UUID) (FoodDto, error) {
 	var food FoodDto
 
-	err := d.DB.Model(&food).Where("id = ?", id).Select()
+	err := d.DB.Model(&food).Where("id = ?", id).Apply(alive).Select()
 
 	if err != nil {
 		return food, err
 	}
 
+	food.Nutrients, err = d.getFoodNutrients(id)
+	if err != nil {
+		return food, err
+	}
+
+	food.Barcodes, err = d.getFoodBarcodes(id)
+	if err != nil {
+		return food, err
+	}
+
 	return food, nil
 }
 
-func (d *DataConn) CreateFood(dto FoodDto) error {
-	_, err := d.DB.Model(&dto).Insert()
+// GetFoodsByIds returns the foods with the given ids that exist, with their
+// relations, in no particular order.
+func (d *DataConn) GetFoodsByIds(ids []uuid.UUID) ([]FoodTableDto, error) {
+	foods := []FoodTableDto{}
+
+	if len(ids) == 0 {
+		return foods, nil
+	}
+
+	err := d.DB.Model(&foods).
+		Relation("User").
+		Relation("FoodType").
+		Relation("Brand").
+		Relation("Servings").
+		Relation("Values").
+		Relation("BarcodeRows").
+		Where("f.id IN (?)", pg.In(ids)).
+		Apply(alive).
+		Select()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range foods {
+		foods[i].Nutrients = nutrientMap(foods[i].Values)
+		foods[i].Barcodes = barcodeList(foods[i].BarcodeRows)
+	}
+
+	return foods, nil
+}
+
+func (d *DataConn) CreateFood(actor Actor, dto FoodDto) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		return createFood(tx, changes, &dto)
+	})
+}
+
+// createFood inserts dto with its nutrients and barcodes inside tx and sets
+// its id.
+func createFood(tx *pg.Tx, changes *changeSet, dto *FoodDto) error {
+	_, err := tx.Model(dto).Insert()
 
 	pgErr, ok := err.(pg.Error)
 	if ok && pgErr.IntegrityViolation() {
-		return fmt.Errorf("name already exists")
+		return conflict("name already exists")
+	}
+
+	if err != nil {
+		return err
 	}
 
-	return err
+	err = replaceFoodNutrients(tx, dto.Id, dto.Nutrients)
+	if err != nil {
+		return err
+	}
+
+	err = replaceFoodBarcodes(tx, dto.Id, dto.Barcodes)
+	if err != nil {
+		return err
+	}
+
+	return changes.created("food", dto.Id, dto)
+}
+
+// EditFood updates the legacy macro columns and replaces the food's nutrient
+// values and barcodes with nutrients and barcodes unless they are nil. It
+// fails with ErrPreconditionFailed unless the food is at version.
+func (d *DataConn) EditFood(actor Actor, name string, kcal float32, protein float32, carbs float32, fat float32, saturated float32, unstaturated float32, fiber float32, sugars float32, nutrients map[string]float32, barcodes []string, brand, foodtype, id uuid.UUID, version int) error {
+	barcodes, err := normalizeBarcodes(barcodes)
+	if err != nil {
+		return err
+	}
+
+	dto := FoodDto{
+		Id:          id,
+		FoodType:    foodtype,
+		Brand:       brand,
+		Name:        name,
+		KCAL:        kcal,
+		Protein:     protein,
+		Carbs:       carbs,
+		Fat:         fat,
+		Saturated:   saturated,
+		Unsaturated: unstaturated,
+		Fiber:       fiber,
+		Sugars:      sugars,
+		Nutrients:   nutrients,
+		Barcodes:    barcodes,
+		Version:     version,
+	}
+
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		return editFood(tx, changes, actor, dto)
+	})
 }
 
-func (d *DataConn) EditFood(name string, kcal float32, protein float32, carbs float32, fat float32, saturated float32, unstaturated float32, fiber float32, sugars float32, brand, foodtype, id uuid.UUID) error {
+// editFood is EditFood inside tx, taking the new values and the expected
+// version from dto.
+func editFood(tx *pg.Tx, changes *changeSet, actor Actor, dto FoodDto) error {
+	before, err := loadFood(tx, dto.Id, true)
+	if err != nil {
+		return err
+	}
+
+	if before.InTrash() {
+		return trashConflict(dto.Id)
+	}
+
+	err = actor.mayChange(before.User)
+	if err != nil {
+		return err
+	}
+
+	err = expectVersion(before.Version, dto.Version)
+	if err != nil {
+		return err
+	}
+
 	var food FoodDto
-	_, err := d.DB.Model(&food).
-		Set("name = ?", name).
-		Set("kcal = ?", kcal).
-		Set("protein = ?", protein).
-		Set("carbs = ?", carbs).
-		Set("fat = ?", fat).
-		Set("saturated = ?", saturated).
-		Set("unsaturated = ?", unstaturated).
-		Set("fiber = ?", fiber).
-		Set("sugars = ?", sugars).
-		Set("brand = ?", brand).
-		Set("food_type = ?", foodtype).
-		Where("id = ?", id).
+	res, err := tx.Model(&food).
+		Set("name = ?", dto.Name).
+		Set("kcal = ?", dto.KCAL).
+		Set("protein = ?", dto.Protein).
+		Set("carbs = ?", dto.Carbs).
+		Set("fat = ?", dto.Fat).
+		Set("saturated = ?", dto.Saturated).
+		Set("unsaturated = ?", dto.Unsaturated).
+		Set("fiber = ?", dto.Fiber).
+		Set("sugars = ?", dto.Sugars).
+		Set("brand = ?", dto.Brand).
+		Set("food_type = ?", dto.FoodType).
+		Where("id = ?", dto.Id).
+		Apply(actor.owns).
 		Update()
-	return err
+
+	pgErr, ok := err.(pg.Error)
+	if ok && pgErr.Field('C') == "23505" {
+		return conflict("name already exists")
+	}
+
+	err = ownedResult(tx, &food, dto.Id, res, err)
+	if err != nil {
+		return err
+	}
+
+	if dto.Nutrients != nil {
+		err = replaceFoodNutrients(tx, dto.Id, dto.Nutrients)
+		if err != nil {
+			return err
+		}
+	}
+
+	if dto.Barcodes != nil {
+		err = replaceFoodBarcodes(tx, dto.Id, dto.Barcodes)
+		if err != nil {
+			return err
+		}
+	}
+
+	after, err := loadFood(tx, dto.Id, false)
+	if err != nil {
+		return err
+	}
+
+	return changes.updated("food", dto.Id, before, after)
 }
 
-func (d *DataConn) DeleteFood(id uuid.UUID) error {
-	var food FoodDto
-	_, err := d.DB.Model(&food).Where("id = ?", id).Delete()
-	return err
+func (d *DataConn) DeleteFood(actor Actor, id uuid.UUID, version int) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := loadFood(tx, id, true)
+		if err != nil {
+			return err
+		}
+
+		if before.InTrash() {
+			return pg.ErrNoRows
+		}
+
+		err = actor.mayChange(before.User)
+		if err != nil {
+			return err
+		}
+
+		err = expectVersion(before.Version, version)
+		if err != nil {
+			return err
+		}
+
+		var food FoodDto
+		res, err := moveToTrash(tx.Model(&food).Where("id = ?", id).Apply(actor.owns), actor.Id)
+		err = ownedResult(tx, &food, id, res, err)
+		if err != nil {
+			return err
+		}
+
+		before.Trash = food.Trash
+		return changes.deleted("food", id, before)
+	})
+}
+
+// ListFoodTrash lists the foods in the trash that actor may restore.
+func (d *DataConn) ListFoodTrash(actor Actor, page Page) ([]FoodTableDto, Cursors, error) {
+	var foods []FoodTableDto
+
+	err := d.DB.Model(&foods).
+		Relation("User").
+		Relation("FoodType").
+		Relation("Brand").
+		Relation("Servings").
+		Relation("Values").
+		Relation("BarcodeRows").
+		Apply(trashed).
+		Apply(actor.owns).
+		Apply(page.apply(withDeletedAt(foodSortColumns))).
+		Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	foods, cursors := paginate(foods, page)
+	for i := range foods {
+		foods[i].Nutrients = nutrientMap(foods[i].Values)
+		foods[i].Barcodes = barcodeList(foods[i].BarcodeRows)
+	}
+
+	return foods, cursors, nil
+}
+
+func (d *DataConn) CountFoodTrash(actor Actor) (int, error) {
+	var foods []FoodTableDto
+
+	count, err := d.DB.Model(&foods).Apply(trashed).Apply(actor.owns).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RestoreFood takes a food out of the trash with the nutrients and barcodes
+// it had.
+func (d *DataConn) RestoreFood(actor Actor, id uuid.UUID) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		var food FoodDto
+		res, err := restoreFromTrash(tx.Model(&food).Where("id = ?", id).Apply(actor.owns))
+		err = restoredResult(tx, &food, id, res, err)
+		if err != nil {
+			return err
+		}
+
+		after, err := loadFood(tx, id, false)
+		if err != nil {
+			return err
+		}
+
+		return changes.restored("food", id, after)
+	})
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type foodExportRow struct {
+	tableName struct{} `pg:"core.food,alias:f"`
+	FoodDto
+	NutrientValues map[string]float32 `pg:"nutrient_values"`
+	BarcodeCodes   []string           `pg:"barcode_codes,array"`
+}
+
+// ExportFoods calls fn for every food matching filter, oldest first, with its
+// nutrients and barcodes, without loading them all into memory.
+func (d *DataConn) ExportFoods(filter FoodFilterDto, fn func(FoodDto) error) error {
+	var row foodExportRow
+	return d.DB.Model(&row).
+		Apply(withFoodDetails).
+		Apply(alive).
+		Apply(filter.apply).
+		Order("f.timestamp ASC", "f.id ASC").
+		ForEach(func(row *foodExportRow) error {
+			return fn(row.food())
+		})
+}
+
+// withFoodDetails selects the nutrients and barcodes of a food into a
+// foodExportRow along with its columns.
+func withFoodDetails(q *orm.Query) (*orm.Query, error) {
+	return q.
+		Column("f.*").
+		ColumnExpr("(SELECT coalesce(jsonb_object_agg(fn.nutrient, fn.amount), '{}') FROM core.food_nutrient AS fn WHERE fn.food = f.id) AS nutrient_values").
+		ColumnExpr("(SELECT coalesce(array_agg(fb.code ORDER BY fb.code), '{}') FROM core.food_barcode AS fb WHERE fb.food = f.id) AS barcode_codes"), nil
+}
+
+func (r foodExportRow) food() FoodDto {
+	food := r.FoodDto
+	food.Nutrients = r.NutrientValues
+	food.Barcodes = r.BarcodeCodes
+	return food
+}
+
+// loadFood reads a food with its nutrients and barcodes inside tx, locking it
+// for the rest of tx when lock is set.
+func loadFood(tx *pg.Tx, id uuid.UUID, lock bool) (FoodDto, error) {
+	var row foodExportRow
+
+	q := tx.Model(&row).Apply(withFoodDetails).Where("f.id = ?", id)
+	if lock {
+		q = q.For("UPDATE OF f")
+	}
+
+	err := q.Select()
+	if err != nil {
+		return FoodDto{}, err
+	}
+
+	return row.food(), nil
+}
+
+// ImportFoods creates the rows without an id and updates those whose id
+// already exists and that actor may edit, replacing their nutrients and
+// barcodes when given, see runImport.
+func (d *DataConn) ImportFoods(actor Actor, rows []ImportRow[FoodDto], report ImportReport) (ImportReport, error) {
+	return runImport(d, actor, rows, report, func(tx *pg.Tx, changes *changeSet, dto FoodDto) error {
+		var before *FoodDto
+		if dto.Id != uuid.Nil {
+			food, err := loadFood(tx, dto.Id, true)
+			if err != nil && err != pg.ErrNoRows {
+				return err
+			}
+
+			if err == nil && food.InTrash() {
+				return trashConflict(dto.Id)
+			}
+
+			if err == nil {
+				before = &food
+			}
+		}
+
+		res, err := tx.Model(&dto).
+			OnConflict("(id) DO UPDATE").
+			Set("name = EXCLUDED.name").
+			Set("food_type = EXCLUDED.food_type").
+			Set("brand = EXCLUDED.brand").
+			Set("kcal = EXCLUDED.kcal").
+			Set("protein = EXCLUDED.protein").
+			Set("carbs = EXCLUDED.carbs").
+			Set("fat = EXCLUDED.fat").
+			Set("saturated = EXCLUDED.saturated").
+			Set("unsaturated = EXCLUDED.unsaturated").
+			Set("fiber = EXCLUDED.fiber").
+			Set("sugars = EXCLUDED.sugars").
+			Apply(actor.owns).
+			Returning("id").
+			Insert()
+
+		err = upsertResult(res, err)
+		if err != nil {
+			return err
+		}
+
+		if dto.Nutrients != nil {
+			err = replaceFoodNutrients(tx, dto.Id, dto.Nutrients)
+			if err != nil {
+				return err
+			}
+		}
+
+		if dto.Barcodes != nil {
+			err = replaceFoodBarcodes(tx, dto.Id, dto.Barcodes)
+			if err != nil {
+				return err
+			}
+		}
+
+		after, err := loadFood(tx, dto.Id, false)
+		if err != nil {
+			return err
+		}
+
+		if before == nil {
+			return changes.created("food", dto.Id, after)
+		}
+
+		return changes.updated("food", dto.Id, before, after)
+	})
+}
+
+// BulkWriteFoods creates the items without an id and updates the others in a
+// single transaction, each in its own savepoint. result must already hold the
+// mode and the errors of items that failed validation. Updates are limited to
+// foods actor may edit, and those with a version to foods still at it. An
+// atomic write is rolled back as soon as result holds any error, in which
+// case no ids are reported.
+func (d *DataConn) BulkWriteFoods(actor Actor, items []BulkItem[FoodDto], result BulkResult) (BulkResult, error) {
+	result.Created = []BulkItemResult{}
+	result.Updated = []BulkItemResult{}
+
+	err := d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		changes := &changeSet{tx: tx, actor: actor}
+
+		for _, item := range items {
+			dto := item.Value
+			update := dto.Id != uuid.Nil
+
+			writeErr, err := savepoint(tx, func() error {
+				if update {
+					return editFood(tx, changes, actor, dto)
+				}
+				return createFood(tx, changes, &dto)
+			})
+			if err != nil {
+				return err
+			}
+
+			if writeErr != nil {
+				result.Errors = append(result.Errors, NewBulkItemError(item.Index, writeErr))
+				continue
+			}
+
+			if update {
+				result.Updated = append(result.Updated, BulkItemResult{Index: item.Index, Id: dto.Id})
+			} else {
+				result.Created = append(result.Created, BulkItemResult{Index: item.Index, Id: dto.Id})
+			}
+		}
+
+		if result.Mode == BulkAtomic && len(result.Errors) > 0 {
+			return errRollback
+		}
+
+		return nil
+	})
+	if err != nil && err != errRollback {
+		return result, err
+	}
+
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Index < result.Errors[j].Index
+	})
+
+	result.Committed = err == nil
+	if !result.Committed {
+		result.Created = []BulkItemResult{}
+		result.Updated = []BulkItemResult{}
+		return result, nil
+	}
+
+	d.outboxReady()
+	return result, nil
 }
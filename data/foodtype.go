@@ -1,10 +1,10 @@
 package data
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
@@ -17,6 +17,8 @@ type FoodTypeDto struct {
 	User      uuid.UUID `json:"user" db:"user"`
 	Category  uuid.UUID `json:"category" db:"category"`
 	Name      string    `json:"name" db:"name" validate:"min=3"`
+	Version   int       `json:"version" db:"version"`
+	Trash
 }
 
 //lint:ignore U1000 Ignore unused function temporarily for debugging
@@ -29,6 +31,8 @@ type FoodTypeTableDto struct {
 	User       *AuthDto     `json:"user" pg:"fk:user,rel:has-one"`
 	Category   *CategoryDto `json:"category" pg:"fk:category,rel:has-one"`
 	Name       string       `json:"name" db:"name" validate:"min=3"`
+	Version    int          `json:"version" db:"version"`
+	Trash
 }
 
 //lint:ignore U1000 Ignore unused function temporarily for debugging
@@ -40,6 +44,16 @@ type FoodTypeFilterDto struct {
 	Skip     uint16    `json:"skip"`
 }
 
+var foodTypeSortColumns = sortColumns{
+	"name":      "ft.name",
+	"timestamp": "ft.timestamp",
+	"category":  `"category".name`,
+}
+
+func (ft FoodTypeTableDto) cursor() Cursor {
+	return Cursor{Timestamp: ft.Timestamp, Id: ft.Id}
+}
+
 func NewFoodType(user uuid.UUID, name string, category uuid.UUID) (*FoodTypeDto, error) {
 	validate := validator.New()
 
@@ -74,26 +88,44 @@ func NewFoodTypeFilterDto(id uuid.UUID, name string, category uuid.UUID) (*FoodT
 	return filter, nil
 }
 
-func (d *DataConn) ListFoodTypes(pageIndex, pageSize int) ([]FoodTypeTableDto, error) {
+func (f FoodTypeFilterDto) apply(q *orm.Query) (*orm.Query, error) {
+	if f.Id != uuid.Nil {
+		q = q.Where("ft.id = ?", f.Id)
+	}
+
+	if f.Name != "" {
+		q = q.Where("ft.name ILIKE ?", containsPattern(f.Name))
+	}
+
+	if f.Category != uuid.Nil {
+		q = q.Where("ft.category = ?", f.Category)
+	}
+
+	return q, nil
+}
+
+func (d *DataConn) ListFoodTypes(filter FoodTypeFilterDto, page Page) ([]FoodTypeTableDto, Cursors, error) {
 	var foodTypes []FoodTypeTableDto
 
 	err := d.DB.Model(&foodTypes).
 		Relation("User").
 		Relation("Category").
-		Limit(pageSize).
-		Offset(pageIndex * pageSize).
+		Apply(alive).
+		Apply(filter.apply).
+		Apply(page.apply(foodTypeSortColumns)).
 		Select()
 	if err != nil {
-		return nil, err
+		return nil, Cursors{}, err
 	}
 
-	return foodTypes, nil
+	foodTypes, cursors := paginate(foodTypes, page)
+	return foodTypes, cursors, nil
 }
 
-func (d *DataConn) CountFoodTypes() (int, error) {
+func (d *DataConn) CountFoodTypes(filter FoodTypeFilterDto) (int, error) {
 	var foodTypes []FoodTypeTableDto
 
-	count, err := d.DB.Model(&foodTypes).Count()
+	count, err := d.DB.Model(&foodTypes).Apply(alive).Apply(filter.apply).Count()
 	if err != nil {
 		return 0, err
 	}
@@ -104,7 +136,7 @@ func (d *DataConn) CountFoodTypes() (int, error) {
 func (d *DataConn) GetFoodTypeById(id uuid.UUID) (FoodTypeDto, error) {
 	var foodType FoodTypeDto
 
-	err := d.DB.Model(&foodType).Where("id = ?", id).Select()
+	err := d.DB.Model(&foodType).Where("id = ?", id).Apply(alive).Select()
 
 	if err != nil {
 		return foodType, err
@@ -113,25 +145,184 @@ func (d *DataConn) GetFoodTypeById(id uuid.UUID) (FoodTypeDto, error) {
 	return foodType, nil
 }
 
-func (d *DataConn) CreateFoodType(dto FoodTypeDto) error {
-	_, err := d.DB.Model(&dto).Insert()
+func (d *DataConn) CreateFoodType(actor Actor, dto FoodTypeDto) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		_, err := tx.Model(&dto).Insert()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.Field('C') == "23505" {
+			return conflict("name already exists")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return changes.created("foodtype", dto.Id, dto)
+	})
+}
 
-	pgErr, ok := err.(pg.Error)
-	if ok && pgErr.IntegrityViolation() {
-		return fmt.Errorf("name already exists")
+// EditFoodType changes a food type. Food types are a taxonomy shared by every
+// user, so ownership does not apply: only curators may change them.
+func (d *DataConn) EditFoodType(actor Actor, id uuid.UUID, version int, name string, category uuid.UUID) error {
+	if !actor.Can(PermTaxonomyWrite) {
+		return ErrForbidden
 	}
 
-	return err
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		before, err := previous[FoodTypeDto](tx, id)
+		if err != nil {
+			return err
+		}
+
+		if before == nil {
+			return pg.ErrNoRows
+		}
+
+		err = expectVersion(before.Version, version)
+		if err != nil {
+			return err
+		}
+
+		var foodType FoodTypeDto
+		_, err = tx.Model(&foodType).Set("name = ?", name).Set("category = ?", category).Where("id = ?", id).Returning("*").Update()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.Field('C') == "23505" {
+			return conflict("name already exists")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return changes.updated("foodtype", id, before, foodType)
+	})
 }
 
-func (d *DataConn) EditFoodType(id uuid.UUID, name string, category uuid.UUID) error {
-	var foodType FoodTypeDto
-	_, err := d.DB.Model(&foodType).Set("name = ?", name).Set("category = ?", category).Where("id = ?", id).Update()
-	return err
+// DeleteFoodType moves a food type to the trash, see EditFoodType.
+func (d *DataConn) DeleteFoodType(actor Actor, id uuid.UUID, version int) error {
+	if !actor.Can(PermTaxonomyWrite) {
+		return ErrForbidden
+	}
+
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		err := lockVersion(tx.Model((*FoodTypeDto)(nil)).Where("id = ?", id), version)
+		if err != nil {
+			return err
+		}
+
+		var foodType FoodTypeDto
+		res, err := moveToTrash(tx.Model(&foodType).Where("id = ?", id), actor.Id)
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return changes.deleted("foodtype", id, foodType)
+	})
+}
+
+// ListFoodTypeTrash lists the food types in the trash, see EditFoodType.
+func (d *DataConn) ListFoodTypeTrash(actor Actor, page Page) ([]FoodTypeTableDto, Cursors, error) {
+	if !actor.Can(PermTaxonomyWrite) {
+		return nil, Cursors{}, ErrForbidden
+	}
+
+	var foodTypes []FoodTypeTableDto
+
+	err := d.DB.Model(&foodTypes).
+		Relation("User").
+		Relation("Category").
+		Apply(trashed).
+		Apply(page.apply(withDeletedAt(foodTypeSortColumns))).
+		Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	foodTypes, cursors := paginate(foodTypes, page)
+	return foodTypes, cursors, nil
 }
 
-func (d *DataConn) DeleteFoodType(id uuid.UUID) error {
+func (d *DataConn) CountFoodTypeTrash(actor Actor) (int, error) {
+	if !actor.Can(PermTaxonomyWrite) {
+		return 0, ErrForbidden
+	}
+
+	var foodTypes []FoodTypeTableDto
+
+	count, err := d.DB.Model(&foodTypes).Apply(trashed).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RestoreFoodType takes a food type out of the trash, see EditFoodType.
+func (d *DataConn) RestoreFoodType(actor Actor, id uuid.UUID) error {
+	if !actor.Can(PermTaxonomyWrite) {
+		return ErrForbidden
+	}
+
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		var foodType FoodTypeDto
+		res, err := restoreFromTrash(tx.Model(&foodType).Where("id = ?", id))
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return changes.restored("foodtype", id, foodType)
+	})
+}
+
+// ExportFoodTypes calls fn for every food type matching filter, oldest first,
+// without loading them all into memory.
+func (d *DataConn) ExportFoodTypes(filter FoodTypeFilterDto, fn func(FoodTypeDto) error) error {
 	var foodType FoodTypeDto
-	_, err := d.DB.Model(&foodType).Where("id = ?", id).Delete()
-	return err
+	return d.DB.Model(&foodType).
+		Apply(alive).
+		Apply(filter.apply).
+		Order("ft.timestamp ASC", "ft.id ASC").
+		ForEach(fn)
+}
+
+// ImportFoodTypes creates the rows without an id and updates those whose id
+// already exists, see runImport. Like EditFoodType it is limited to curators.
+func (d *DataConn) ImportFoodTypes(actor Actor, rows []ImportRow[FoodTypeDto], report ImportReport) (ImportReport, error) {
+	if !actor.Can(PermTaxonomyWrite) {
+		return report, ErrForbidden
+	}
+
+	return runImport(d, actor, rows, report, func(tx *pg.Tx, changes *changeSet, dto FoodTypeDto) error {
+		before, err := previous[FoodTypeDto](tx, dto.Id)
+		if err != nil {
+			return err
+		}
+
+		res, err := tx.Model(&dto).
+			OnConflict("(id) DO UPDATE").
+			Set("name = EXCLUDED.name").
+			Set("category = EXCLUDED.category").
+			Returning("*").
+			Insert()
+		err = upsertResult(res, err)
+		if err != nil {
+			return err
+		}
+
+		if before == nil {
+			return changes.created("foodtype", dto.Id, dto)
+		}
+
+		return changes.updated("foodtype", dto.Id, before, dto)
+	})
 }
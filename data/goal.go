@@ -0,0 +1,359 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// maxProgressDays bounds the range a progress report may cover.
+const maxProgressDays = 366
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type GoalDto struct {
+	tableName     struct{}  `pg:"core.goal,alias:g"`
+	Id            uuid.UUID `json:"id" db:"id"`
+	Timestamp     time.Time `json:"timestamp" db:"timestamp"`
+	User          uuid.UUID `json:"user" db:"user"`
+	EffectiveFrom time.Time `json:"effectiveFrom" db:"effective_from" pg:"type:date" validate:"required"`
+	KCAL          *float32  `json:"kcal" db:"kcal" validate:"omitempty,gt=0"`
+	Protein       *float32  `json:"protein" db:"protein" validate:"omitempty,gte=0"`
+	Carbs         *float32  `json:"carbs" db:"carbs" validate:"omitempty,gte=0"`
+	Fat           *float32  `json:"fat" db:"fat" validate:"omitempty,gte=0"`
+	Fiber         *float32  `json:"fiber" db:"fiber" validate:"omitempty,gte=0"`
+	Sugars        *float32  `json:"sugars" db:"sugars" validate:"omitempty,gte=0"`
+	Version       int       `json:"version" db:"version"`
+	Trash
+}
+
+// NutrientProgressDto compares what was eaten with a single goal. Sugars is a
+// cap, so for it Over is the flag to watch rather than Under.
+type NutrientProgressDto struct {
+	Goal       float32 `json:"goal"`
+	Consumed   float32 `json:"consumed"`
+	Remaining  float32 `json:"remaining"`
+	Percentage float32 `json:"percentage"`
+	Cap        bool    `json:"cap"`
+	Over       bool    `json:"over"`
+	Under      bool    `json:"under"`
+}
+
+type GoalProgressDayDto struct {
+	Date      string                         `json:"date"`
+	Goal      *GoalDto                       `json:"goal"`
+	Consumed  NutritionDto                   `json:"consumed"`
+	Nutrients map[string]NutrientProgressDto `json:"nutrients"`
+}
+
+type GoalProgressDto struct {
+	From     string                         `json:"from"`
+	To       string                         `json:"to"`
+	TimeZone string                         `json:"timeZone"`
+	Days     []GoalProgressDayDto           `json:"days"`
+	Total    map[string]NutrientProgressDto `json:"total"`
+}
+
+var goalSortColumns = sortColumns{
+	"effectiveFrom": "g.effective_from",
+}
+
+func (g GoalDto) cursor() Cursor {
+	return Cursor{Timestamp: g.Timestamp, Id: g.Id}
+}
+
+// targets lists the goals that are set, keyed like the NutritionDto json fields.
+func (g GoalDto) targets() map[string]float32 {
+	targets := map[string]float32{}
+
+	for key, value := range map[string]*float32{
+		"kcal":    g.KCAL,
+		"protein": g.Protein,
+		"carbs":   g.Carbs,
+		"fat":     g.Fat,
+		"fiber":   g.Fiber,
+		"sugars":  g.Sugars,
+	} {
+		if value != nil {
+			targets[key] = *value
+		}
+	}
+
+	return targets
+}
+
+func (n NutritionDto) value(key string) float32 {
+	switch key {
+	case "kcal":
+		return n.KCAL
+	case "protein":
+		return n.Protein
+	case "carbs":
+		return n.Carbs
+	case "fat":
+		return n.Fat
+	case "fiber":
+		return n.Fiber
+	case "sugars":
+		return n.Sugars
+	}
+
+	return 0
+}
+
+func newNutrientProgress(key string, goal, consumed float32) NutrientProgressDto {
+	progress := NutrientProgressDto{
+		Goal:      goal,
+		Consumed:  consumed,
+		Remaining: goal - consumed,
+		Cap:       key == "sugars",
+		Over:      consumed > goal,
+		Under:     consumed < goal,
+	}
+
+	if goal > 0 {
+		progress.Percentage = consumed / goal * 100
+	}
+
+	return progress
+}
+
+func NewGoal(user uuid.UUID, effectiveFrom time.Time, kcal, protein, carbs, fat, fiber, sugars *float32) (*GoalDto, error) {
+	validate := validator.New()
+
+	goal := &GoalDto{
+		User:          user,
+		EffectiveFrom: effectiveFrom,
+		KCAL:          kcal,
+		Protein:       protein,
+		Carbs:         carbs,
+		Fat:           fat,
+		Fiber:         fiber,
+		Sugars:        sugars,
+	}
+
+	err := validate.Struct(goal)
+	if err != nil {
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+func (d *DataConn) ListGoals(user uuid.UUID, page Page) ([]GoalDto, Cursors, error) {
+	var goals []GoalDto
+
+	err := d.DB.Model(&goals).
+		Where(`g."user" = ?`, user).
+		Apply(alive).
+		Apply(page.apply(goalSortColumns)).
+		Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	goals, cursors := paginate(goals, page)
+	return goals, cursors, nil
+}
+
+func (d *DataConn) CountGoals(user uuid.UUID) (int, error) {
+	var goals []GoalDto
+
+	count, err := d.DB.Model(&goals).Where(`g."user" = ?`, user).Apply(alive).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// GetGoalAt returns the goal version in effect on date.
+func (d *DataConn) GetGoalAt(user uuid.UUID, date time.Time) (GoalDto, error) {
+	var goal GoalDto
+
+	err := d.DB.Model(&goal).
+		Where(`g."user" = ?`, user).
+		Where("g.effective_from <= ?", date.Format(time.DateOnly)).
+		Apply(alive).
+		Order("g.effective_from DESC").
+		Limit(1).
+		Select()
+
+	if err != nil {
+		return goal, err
+	}
+
+	return goal, nil
+}
+
+// CreateGoal adds a goal version, replacing a live one with the same
+// effective date.
+func (d *DataConn) CreateGoal(dto GoalDto) error {
+	_, err := d.DB.Model(&dto).
+		OnConflict(`("user", effective_from) WHERE deleted_at IS NULL DO UPDATE`).
+		Set("timestamp = now()").
+		Set("kcal = EXCLUDED.kcal").
+		Set("protein = EXCLUDED.protein").
+		Set("carbs = EXCLUDED.carbs").
+		Set("fat = EXCLUDED.fat").
+		Set("fiber = EXCLUDED.fiber").
+		Set("sugars = EXCLUDED.sugars").
+		Insert()
+	return err
+}
+
+func (d *DataConn) DeleteGoal(user, id uuid.UUID, version int) error {
+	return d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		err := lockVersion(tx.Model((*GoalDto)(nil)).Where("id = ?", id).Where(`"user" = ?`, user), version)
+		if err != nil {
+			return err
+		}
+
+		var goal GoalDto
+		res, err := moveToTrash(tx.Model(&goal).Where("id = ?", id).Where(`"user" = ?`, user), user)
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return nil
+	})
+}
+
+func (d *DataConn) ListGoalTrash(user uuid.UUID, page Page) ([]GoalDto, Cursors, error) {
+	var goals []GoalDto
+
+	err := d.DB.Model(&goals).
+		Where(`g."user" = ?`, user).
+		Apply(trashed).
+		Apply(page.apply(withDeletedAt(goalSortColumns))).
+		Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	goals, cursors := paginate(goals, page)
+	return goals, cursors, nil
+}
+
+func (d *DataConn) CountGoalTrash(user uuid.UUID) (int, error) {
+	var goals []GoalDto
+
+	count, err := d.DB.Model(&goals).Where(`g."user" = ?`, user).Apply(trashed).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RestoreGoal takes a goal version out of the trash. It conflicts with a live
+// version with the same effective date.
+func (d *DataConn) RestoreGoal(user, id uuid.UUID) error {
+	var goal GoalDto
+	res, err := restoreFromTrash(d.DB.Model(&goal).Where("id = ?", id).Where(`"user" = ?`, user))
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetGoalProgress compares the diary of every day from..to (inclusive, in
+// loc) with the goal version in effect that day, plus the whole range.
+func (d *DataConn) GetGoalProgress(user uuid.UUID, from, to time.Time, loc *time.Location) (GoalProgressDto, error) {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	last := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc)
+	end := last.AddDate(0, 0, 1)
+
+	progress := GoalProgressDto{
+		From:     start.Format(time.DateOnly),
+		To:       last.Format(time.DateOnly),
+		TimeZone: loc.String(),
+		Days:     []GoalProgressDayDto{},
+		Total:    map[string]NutrientProgressDto{},
+	}
+
+	if last.Before(start) || !last.Before(start.AddDate(0, 0, maxProgressDays)) {
+		return progress, errors.New("invalid date range")
+	}
+
+	var goals []GoalDto
+	err := d.DB.Model(&goals).
+		Where(`g."user" = ?`, user).
+		Where("g.effective_from <= ?", progress.To).
+		Apply(alive).
+		Order("g.effective_from ASC").
+		Select()
+	if err != nil {
+		return progress, err
+	}
+
+	var entries []DiaryEntryTableDto
+	err = d.DB.Model(&entries).
+		Relation("Food").
+		Where(`de."user" = ?`, user).
+		Where("de.eaten_at >= ?", start).
+		Where("de.eaten_at < ?", end).
+		Apply(alive).
+		Select()
+	if err != nil {
+		return progress, err
+	}
+
+	consumed := map[string]NutritionDto{}
+	for i := range entries {
+		entry := &entries[i]
+		entry.computeNutrition()
+
+		date := entry.EatenAt.In(loc).Format(time.DateOnly)
+		consumed[date] = consumed[date].Add(entry.Nutrition)
+	}
+
+	totalGoal := map[string]float32{}
+	totalConsumed := map[string]float32{}
+
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+
+		row := GoalProgressDayDto{
+			Date:      date,
+			Consumed:  consumed[date],
+			Nutrients: map[string]NutrientProgressDto{},
+		}
+
+		for i := range goals {
+			if goals[i].EffectiveFrom.Format(time.DateOnly) <= date {
+				row.Goal = &goals[i]
+			}
+		}
+
+		if row.Goal != nil {
+			for key, goal := range row.Goal.targets() {
+				value := row.Consumed.value(key)
+				row.Nutrients[key] = newNutrientProgress(key, goal, value)
+
+				totalGoal[key] += goal
+				totalConsumed[key] += value
+			}
+		}
+
+		progress.Days = append(progress.Days, row)
+	}
+
+	for key, goal := range totalGoal {
+		progress.Total[key] = newNutrientProgress(key, goal, totalConsumed[key])
+	}
+
+	return progress, nil
+}
@@ -0,0 +1,183 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+)
+
+// EnsureBrand returns the id of the brand called name, ignoring case, and
+// creates it on behalf of actor when there is none yet.
+func (d *DataConn) EnsureBrand(actor Actor, name string) (uuid.UUID, error) {
+	var brand BrandDto
+
+	err := d.DB.Model(&brand).Where("lower(b.name) = lower(?)", name).Apply(alive).Limit(1).Select()
+	if err == nil {
+		return brand.Id, nil
+	}
+
+	if err != pg.ErrNoRows {
+		return uuid.Nil, err
+	}
+
+	dto, err := NewBrandDto(actor.Id, name)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		res, err := tx.Model(dto).
+			OnConflict("(name) WHERE deleted_at IS NULL DO NOTHING").
+			Returning("*").
+			Insert()
+		if err != nil {
+			return err
+		}
+
+		// Created concurrently under the same name: use that one.
+		if res.RowsAffected() == 0 {
+			return tx.Model(dto).Column("id").Where("name = ?", name).Apply(alive).Select()
+		}
+
+		return changes.created("brand", dto.Id, dto)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return dto.Id, nil
+}
+
+// EnsureCategory is EnsureBrand for categories. Only actors that may write
+// the taxonomy create one; others get ErrForbidden for a name that does not
+// exist yet.
+func (d *DataConn) EnsureCategory(actor Actor, name string) (uuid.UUID, error) {
+	var category CategoryDto
+
+	err := d.DB.Model(&category).Where("lower(c.name) = lower(?)", name).Apply(alive).Limit(1).Select()
+	if err == nil {
+		return category.Id, nil
+	}
+
+	if err != pg.ErrNoRows {
+		return uuid.Nil, err
+	}
+
+	if !actor.Can(PermTaxonomyWrite) {
+		return uuid.Nil, fmt.Errorf("%w: no category %q, and creating one needs %v", ErrForbidden, name, PermTaxonomyWrite)
+	}
+
+	dto, err := NewCategoryDto(actor.Id, name)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		res, err := tx.Model(dto).
+			OnConflict("(name) WHERE deleted_at IS NULL DO NOTHING").
+			Returning("*").
+			Insert()
+		if err != nil {
+			return err
+		}
+
+		// Created concurrently under the same name: use that one.
+		if res.RowsAffected() == 0 {
+			return tx.Model(dto).Column("id").Where("name = ?", name).Apply(alive).Select()
+		}
+
+		return changes.created("category", dto.Id, dto)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return dto.Id, nil
+}
+
+// EnsureFoodType is EnsureCategory for food types. An existing food type is
+// reused even when it belongs to another category.
+func (d *DataConn) EnsureFoodType(actor Actor, name string, category uuid.UUID) (uuid.UUID, error) {
+	var foodType FoodTypeDto
+
+	err := d.DB.Model(&foodType).Where("lower(ft.name) = lower(?)", name).Apply(alive).Limit(1).Select()
+	if err == nil {
+		return foodType.Id, nil
+	}
+
+	if err != pg.ErrNoRows {
+		return uuid.Nil, err
+	}
+
+	if !actor.Can(PermTaxonomyWrite) {
+		return uuid.Nil, fmt.Errorf("%w: no food type %q, and creating one needs %v", ErrForbidden, name, PermTaxonomyWrite)
+	}
+
+	dto, err := NewFoodType(actor.Id, name, category)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		res, err := tx.Model(dto).
+			OnConflict("(name) WHERE deleted_at IS NULL DO NOTHING").
+			Returning("*").
+			Insert()
+		if err != nil {
+			return err
+		}
+
+		// Created concurrently under the same name: use that one.
+		if res.RowsAffected() == 0 {
+			return tx.Model(dto).Column("id").Where("name = ?", name).Apply(alive).Select()
+		}
+
+		return changes.created("foodtype", dto.Id, dto)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return dto.Id, nil
+}
+
+// FindFood looks for a food by its normalized barcode first and then by brand
+// and name, ignoring case. It returns nil when neither matches.
+func (d *DataConn) FindFood(barcode string, brand uuid.UUID, name string) (*FoodDto, error) {
+	var found FoodDto
+
+	if barcode != "" {
+		err := d.DB.Model(&found).
+			Column("f.id").
+			Where("f.id = (SELECT fb.food FROM core.food_barcode AS fb WHERE fb.code = ?)", barcode).
+			Apply(alive).
+			Select()
+		if err != nil && err != pg.ErrNoRows {
+			return nil, err
+		}
+
+		if err == nil {
+			food, err := d.GetFoodById(found.Id)
+			return &food, err
+		}
+	}
+
+	err := d.DB.Model(&found).
+		Column("f.id").
+		Where("f.brand = ?", brand).
+		Where("lower(f.name) = lower(?)", name).
+		Apply(alive).
+		Limit(1).
+		Select()
+	if err == pg.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	food, err := d.GetFoodById(found.Id)
+	return &food, err
+}
@@ -0,0 +1,221 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type NutrientDto struct {
+	tableName struct{}  `pg:"core.nutrient,alias:n"`
+	Code      string    `json:"code" pg:"code,pk" validate:"min=1,max=50,lowercase"`
+	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+	Name      string    `json:"name" db:"name" validate:"min=1"`
+	Unit      string    `json:"unit" db:"unit" validate:"oneof=g mg µg kcal kJ IU"`
+	Category  string    `json:"category" db:"category" validate:"oneof=mineral vitamin lipid carbohydrate protein other"`
+	Version   int       `json:"version" db:"version"`
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type FoodNutrientDto struct {
+	tableName struct{}  `pg:"core.food_nutrient,alias:fn"`
+	Food      uuid.UUID `json:"food" pg:"food,pk"`
+	Nutrient  string    `json:"nutrient" pg:"nutrient,pk"`
+	Amount    float32   `json:"amount" pg:"amount,use_zero"`
+}
+
+func NewNutrient(code, name, unit, category string) (*NutrientDto, error) {
+	validate := validator.New()
+
+	nutrient := &NutrientDto{
+		Code:     code,
+		Name:     name,
+		Unit:     unit,
+		Category: category,
+	}
+
+	err := validate.Struct(nutrient)
+	if err != nil {
+		return nil, err
+	}
+
+	return nutrient, nil
+}
+
+func (d *DataConn) ListNutrients(category string) ([]NutrientDto, error) {
+	nutrients := []NutrientDto{}
+
+	err := d.DB.Model(&nutrients).
+		Apply(func(q *orm.Query) (*orm.Query, error) {
+			if category != "" {
+				q = q.Where("n.category = ?", category)
+			}
+			return q, nil
+		}).
+		Order("n.category ASC", "n.code ASC").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+
+	return nutrients, nil
+}
+
+func (d *DataConn) GetNutrientByCode(code string) (NutrientDto, error) {
+	var nutrient NutrientDto
+
+	err := d.DB.Model(&nutrient).Where("code = ?", code).Select()
+
+	if err != nil {
+		return nutrient, err
+	}
+
+	return nutrient, nil
+}
+
+// CreateNutrient adds a catalogue entry, see EditNutrient.
+func (d *DataConn) CreateNutrient(actor Actor, dto NutrientDto) error {
+	if !actor.Can(PermNutrientWrite) {
+		return ErrForbidden
+	}
+
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		_, err := tx.Model(&dto).Insert()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.IntegrityViolation() {
+			return fmt.Errorf("code already exists")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return changes.created("nutrient", dto.Code, dto)
+	})
+}
+
+// EditNutrient changes a catalogue entry. Nutrients are shared by every food
+// and have no owner, so only actors with PermNutrientWrite may change them.
+func (d *DataConn) EditNutrient(actor Actor, code string, version int, name, unit, category string) error {
+	if !actor.Can(PermNutrientWrite) {
+		return ErrForbidden
+	}
+
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		var before NutrientDto
+		err := tx.Model(&before).Where("code = ?", code).For("UPDATE").Select()
+		if err != nil {
+			return err
+		}
+
+		err = expectVersion(before.Version, version)
+		if err != nil {
+			return err
+		}
+
+		var nutrient NutrientDto
+		_, err = tx.Model(&nutrient).
+			Set("name = ?", name).
+			Set("unit = ?", unit).
+			Set("category = ?", category).
+			Where("code = ?", code).
+			Returning("*").
+			Update()
+		if err != nil {
+			return err
+		}
+
+		return changes.updated("nutrient", code, before, nutrient)
+	})
+}
+
+// DeleteNutrient removes an unused catalogue entry, see EditNutrient.
+func (d *DataConn) DeleteNutrient(actor Actor, code string, version int) error {
+	if !actor.Can(PermNutrientWrite) {
+		return ErrForbidden
+	}
+
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		err := lockVersion(tx.Model((*NutrientDto)(nil)).Where("code = ?", code), version)
+		if err != nil {
+			return err
+		}
+
+		var nutrient NutrientDto
+		res, err := tx.Model(&nutrient).Where("code = ?", code).Returning("*").Delete()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.IntegrityViolation() {
+			return fmt.Errorf("nutrient is in use")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return changes.deleted("nutrient", code, nutrient)
+	})
+}
+
+// nutrientMap turns food nutrient rows into the code to amount map the API
+// exposes next to the legacy macro fields.
+func nutrientMap(rows []FoodNutrientDto) map[string]float32 {
+	nutrients := make(map[string]float32, len(rows))
+	for _, row := range rows {
+		nutrients[row.Nutrient] = row.Amount
+	}
+
+	return nutrients
+}
+
+func (d *DataConn) getFoodNutrients(food uuid.UUID) (map[string]float32, error) {
+	var rows []FoodNutrientDto
+
+	err := d.DB.Model(&rows).Where("fn.food = ?", food).Select()
+	if err != nil {
+		return nil, err
+	}
+
+	return nutrientMap(rows), nil
+}
+
+// replaceFoodNutrients swaps the nutrient values of a food inside tx.
+func replaceFoodNutrients(tx *pg.Tx, food uuid.UUID, nutrients map[string]float32) error {
+	var existing FoodNutrientDto
+	_, err := tx.Model(&existing).Where("food = ?", food).Delete()
+	if err != nil {
+		return err
+	}
+
+	if len(nutrients) == 0 {
+		return nil
+	}
+
+	rows := make([]FoodNutrientDto, 0, len(nutrients))
+	for code, amount := range nutrients {
+		rows = append(rows, FoodNutrientDto{Food: food, Nutrient: code, Amount: amount})
+	}
+
+	_, err = tx.Model(&rows).Insert()
+
+	pgErr, ok := err.(pg.Error)
+	if ok && pgErr.Field('C') == "23503" {
+		return fmt.Errorf("unknown nutrient")
+	}
+
+	if ok && pgErr.Field('C') == "23514" {
+		return fmt.Errorf("nutrient amounts must not be negative")
+	}
+
+	return err
+}
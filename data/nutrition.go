@@ -0,0 +1,58 @@
+package data
+
+// NutritionDto holds the nutrient values of an amount of food. Foods store
+// theirs per 100 g, so amounts are scaled by grams / 100.
+type NutritionDto struct {
+	KCAL        float32 `json:"kcal"`
+	Protein     float32 `json:"protein"`
+	Carbs       float32 `json:"carbs"`
+	Fat         float32 `json:"fat"`
+	Saturated   float32 `json:"saturated"`
+	Unsaturated float32 `json:"unsaturated"`
+	Fiber       float32 `json:"fiber"`
+	Sugars      float32 `json:"sugars"`
+}
+
+func (f FoodDto) Nutrition() NutritionDto {
+	return NutritionDto{
+		KCAL:        f.KCAL,
+		Protein:     f.Protein,
+		Carbs:       f.Carbs,
+		Fat:         f.Fat,
+		Saturated:   f.Saturated,
+		Unsaturated: f.Unsaturated,
+		Fiber:       f.Fiber,
+		Sugars:      f.Sugars,
+	}
+}
+
+// ForGrams scales per 100 g values to the given weight.
+func (n NutritionDto) ForGrams(grams float32) NutritionDto {
+	return n.Scale(grams / 100)
+}
+
+func (n NutritionDto) Scale(factor float32) NutritionDto {
+	return NutritionDto{
+		KCAL:        n.KCAL * factor,
+		Protein:     n.Protein * factor,
+		Carbs:       n.Carbs * factor,
+		Fat:         n.Fat * factor,
+		Saturated:   n.Saturated * factor,
+		Unsaturated: n.Unsaturated * factor,
+		Fiber:       n.Fiber * factor,
+		Sugars:      n.Sugars * factor,
+	}
+}
+
+func (n NutritionDto) Add(other NutritionDto) NutritionDto {
+	return NutritionDto{
+		KCAL:        n.KCAL + other.KCAL,
+		Protein:     n.Protein + other.Protein,
+		Carbs:       n.Carbs + other.Carbs,
+		Fat:         n.Fat + other.Fat,
+		Saturated:   n.Saturated + other.Saturated,
+		Unsaturated: n.Unsaturated + other.Unsaturated,
+		Fiber:       n.Fiber + other.Fiber,
+		Sugars:      n.Sugars + other.Sugars,
+	}
+}
@@ -0,0 +1,296 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/adamelfsborg-code/food/culinary/events"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	OutboxPending   = "pending"
+	OutboxPublished = "published"
+	OutboxDead      = "dead"
+)
+
+const (
+	// outboxMaxAttempts failed publishes move an event to OutboxDead.
+	outboxMaxAttempts = 10
+	// outboxMaxBackoff caps the wait between two attempts.
+	outboxMaxBackoff = 5 * time.Minute
+	// outboxClaim is how long a relay has to publish the events it claimed
+	// before another relay may take them over.
+	outboxClaim = time.Minute
+)
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type OutboxDto struct {
+	tableName   struct{}        `pg:"core.outbox,alias:o"`
+	Id          uuid.UUID       `json:"id" pg:"id"`
+	Timestamp   time.Time       `json:"timestamp" pg:"timestamp"`
+	Type        string          `json:"type" pg:"type"`
+	Payload     json.RawMessage `json:"payload" pg:"payload,type:jsonb"`
+	Status      string          `json:"status" pg:"status"`
+	Attempts    int             `json:"attempts" pg:"attempts,use_zero"`
+	NextAttempt time.Time       `json:"nextAttempt" pg:"next_attempt"`
+	LastError   *string         `json:"lastError" pg:"last_error"`
+	PublishedAt *time.Time      `json:"publishedAt" pg:"published_at"`
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type OutboxFilterDto struct {
+	Status string `json:"status" validate:"omitempty,oneof=pending published dead"`
+	Type   string `json:"type"`
+}
+
+var outboxSortColumns = sortColumns{
+	"timestamp": "o.timestamp",
+	"attempts":  "o.attempts",
+}
+
+func (o OutboxDto) cursor() Cursor {
+	return Cursor{Timestamp: o.Timestamp, Id: o.Id}
+}
+
+func NewOutboxFilterDto(status, eventType string) (*OutboxFilterDto, error) {
+	validate := validator.New()
+
+	filter := &OutboxFilterDto{
+		Status: status,
+		Type:   eventType,
+	}
+
+	err := validate.Struct(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func (f OutboxFilterDto) apply(q *orm.Query) (*orm.Query, error) {
+	if f.Status != "" {
+		q = q.Where("o.status = ?", f.Status)
+	}
+
+	if f.Type != "" {
+		q = q.Where("o.type = ?", f.Type)
+	}
+
+	return q, nil
+}
+
+func (d *DataConn) ListOutbox(filter OutboxFilterDto, page Page) ([]OutboxDto, Cursors, error) {
+	var rows []OutboxDto
+
+	err := d.DB.Model(&rows).Apply(filter.apply).Apply(page.apply(outboxSortColumns)).Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	rows, cursors := paginate(rows, page)
+	return rows, cursors, nil
+}
+
+func (d *DataConn) CountOutbox(filter OutboxFilterDto) (int, error) {
+	var rows []OutboxDto
+
+	count, err := d.DB.Model(&rows).Apply(filter.apply).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// RetryOutbox queues a dead event for publishing again with a fresh attempt
+// budget.
+func (d *DataConn) RetryOutbox(id uuid.UUID) error {
+	var row OutboxDto
+	res, err := d.DB.Model(&row).
+		Set("status = ?", OutboxPending).
+		Set("attempts = 0").
+		Set("next_attempt = now()").
+		Where("id = ?", id).
+		Where("status = ?", OutboxDead).
+		Update()
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		exists, err := d.DB.Model(&row).Where("id = ?", id).Exists()
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return pg.ErrNoRows
+		}
+
+		return conflict("only dead events can be retried")
+	}
+
+	return nil
+}
+
+// addOutbox stores event in the outbox inside tx, so it is published if and
+// only if tx commits.
+func addOutbox(tx *pg.Tx, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	row := OutboxDto{
+		Id:        event.Id,
+		Timestamp: event.Timestamp,
+		Type:      event.Type,
+		Payload:   payload,
+		Status:    OutboxPending,
+	}
+
+	_, err = tx.Model(&row).Insert()
+	return err
+}
+
+// outboxBackoff is the wait before attempt number attempts+1: one second,
+// doubling with every failure up to outboxMaxBackoff.
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 16 {
+		return outboxMaxBackoff
+	}
+
+	return min(time.Second<<attempts, outboxMaxBackoff)
+}
+
+// outboxStatus is the status of an event after attempts failed publishes.
+func outboxStatus(attempts int) string {
+	if attempts >= outboxMaxAttempts {
+		return OutboxDead
+	}
+
+	return OutboxPending
+}
+
+// RelayOutbox publishes up to limit due events, oldest first, and returns how
+// many it handled. Rows are claimed in a short transaction that pushes their
+// next attempt outboxClaim ahead and are published after it commits, so a
+// slow broker holds no transaction open. A pending event that is not due yet,
+// because it is claimed or failed, holds back every event after it, so events
+// stay in order while NATS is unavailable. Only a dead event stops doing so.
+// Each event is published with its id as Nats-Msg-Id, so JetStream drops the
+// copy sent again when an instance dies between publishing and marking the
+// row and its claim runs out.
+func (d *DataConn) RelayOutbox(ctx context.Context, limit int) (int, error) {
+	if d.JS == nil {
+		return 0, errors.New("no JetStream connection")
+	}
+
+	var rows []OutboxDto
+
+	err := d.DB.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		err := tx.Model(&rows).
+			Where("o.status = ?", OutboxPending).
+			Where("o.next_attempt <= now()").
+			Where(`NOT EXISTS (
+				SELECT 1 FROM core.outbox AS w
+				WHERE w.status = ? AND w.next_attempt > now() AND (w.timestamp, w.id) < (o.timestamp, o.id)
+			)`, OutboxPending).
+			Order("o.timestamp ASC", "o.id ASC").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Select()
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		_, err = tx.Model((*OutboxDto)(nil)).
+			Set("next_attempt = ?", time.Now().Add(outboxClaim)).
+			Where("id IN (?)", pg.In(outboxIds(rows))).
+			Update()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i, row := range rows {
+		err := events.Publish(d.JS, d.Env.EventsSubject, row.Type, row.Id, row.Payload)
+		if err != nil {
+			return i, d.failOutbox(ctx, row, rows[i+1:], err)
+		}
+
+		_, err = d.DB.ModelContext(ctx, &row).
+			Set("status = ?", OutboxPublished).
+			Set("attempts = attempts + 1").
+			Set("published_at = now()").
+			Set("last_error = NULL").
+			WherePK().
+			Update()
+		if err != nil {
+			return i, err
+		}
+	}
+
+	return len(rows), nil
+}
+
+func outboxIds(rows []OutboxDto) []uuid.UUID {
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.Id
+	}
+
+	return ids
+}
+
+// PurgeOutbox deletes the events published before cutoff and returns how many
+// it deleted. Pending and dead events are kept however old they are.
+func (d *DataConn) PurgeOutbox(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := d.DB.ModelContext(ctx, (*OutboxDto)(nil)).
+		Where("status = ?", OutboxPublished).
+		Where("published_at < ?", cutoff).
+		Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// failOutbox records a failed publish of row, scheduling another attempt or
+// giving up on it, and releases the claim on rest, the rows of the batch
+// after it, which wait behind it again.
+func (d *DataConn) failOutbox(ctx context.Context, row OutboxDto, rest []OutboxDto, cause error) error {
+	attempts := row.Attempts + 1
+	status := outboxStatus(attempts)
+
+	fmt.Println("Failed to publish event: ", row.Type, row.Id, cause)
+
+	return d.DB.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		_, err := tx.Model(&row).
+			Set("status = ?", status).
+			Set("attempts = ?", attempts).
+			Set("next_attempt = ?", time.Now().Add(outboxBackoff(attempts))).
+			Set("last_error = ?", cause.Error()).
+			WherePK().
+			Update()
+		if err != nil || len(rest) == 0 {
+			return err
+		}
+
+		_, err = tx.Model((*OutboxDto)(nil)).
+			Set("next_attempt = now()").
+			Where("id IN (?)", pg.In(outboxIds(rest))).
+			Update()
+		return err
+	})
+}
@@ -0,0 +1,58 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{8, 256 * time.Second},
+		{9, outboxMaxBackoff},
+		{16, outboxMaxBackoff},
+		{17, outboxMaxBackoff},
+		{63, outboxMaxBackoff},
+		{64, outboxMaxBackoff},
+		{1000, outboxMaxBackoff},
+	}
+
+	for _, tt := range tests {
+		got := outboxBackoff(tt.attempts)
+		if got != tt.want {
+			t.Errorf("outboxBackoff(%v) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestOutboxBackoffGrows(t *testing.T) {
+	for attempts := 1; attempts < 100; attempts++ {
+		if outboxBackoff(attempts) < outboxBackoff(attempts-1) {
+			t.Errorf("outboxBackoff(%v) = %v is shorter than outboxBackoff(%v) = %v", attempts, outboxBackoff(attempts), attempts-1, outboxBackoff(attempts-1))
+		}
+	}
+}
+
+func TestOutboxStatus(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     string
+	}{
+		{1, OutboxPending},
+		{outboxMaxAttempts - 1, OutboxPending},
+		{outboxMaxAttempts, OutboxDead},
+		{outboxMaxAttempts + 1, OutboxDead},
+	}
+
+	for _, tt := range tests {
+		got := outboxStatus(tt.attempts)
+		if got != tt.want {
+			t.Errorf("outboxStatus(%v) = %q, want %q", tt.attempts, got, tt.want)
+		}
+	}
+}
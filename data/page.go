@@ -0,0 +1,203 @@
+package data
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+)
+
+// Page selects a window of rows ordered newest first, or by Sort when given.
+// When Cursor is set the window starts after the cursor row (keyset paging),
+// otherwise Index and Size are used as a plain offset.
+type Page struct {
+	Index  int
+	Size   int
+	Cursor *Cursor
+	Sort   string
+}
+
+// Cursor points at a row by its (timestamp, id) key. Backward cursors page
+// towards newer rows.
+type Cursor struct {
+	Timestamp time.Time
+	Id        uuid.UUID
+	Backward  bool
+}
+
+// Cursors holds the opaque tokens for the pages around a returned page.
+type Cursors struct {
+	Next string
+	Prev string
+}
+
+type cursorToken struct {
+	Timestamp int64     `json:"t"`
+	Id        uuid.UUID `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+type keyed interface {
+	cursor() Cursor
+}
+
+// sortColumns maps the names a client may pass in Page.Sort to the SQL
+// expressions they order by.
+type sortColumns map[string]string
+
+func EncodeCursor(cursor Cursor) string {
+	token, _ := json.Marshal(cursorToken{
+		Timestamp: cursor.Timestamp.UnixMicro(),
+		Id:        cursor.Id,
+		Backward:  cursor.Backward,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+func DecodeCursor(value string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	var token cursorToken
+	err = json.Unmarshal(raw, &token)
+	if err != nil || token.Id == uuid.Nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	cursor := &Cursor{
+		Timestamp: time.UnixMicro(token.Timestamp).UTC(),
+		Id:        token.Id,
+		Backward:  token.Backward,
+	}
+
+	return cursor, nil
+}
+
+// apply orders the query by Sort or (timestamp, id) and limits it to one row
+// more than the page size, so paginate can tell whether another page exists.
+func (p Page) apply(columns sortColumns) func(q *orm.Query) (*orm.Query, error) {
+	return func(q *orm.Query) (*orm.Query, error) {
+		if p.Sort == "" {
+			return p.applyKeyset(q)
+		}
+
+		if p.Cursor != nil {
+			return nil, errors.New("cursor paging does not support sort")
+		}
+
+		order, err := parseSort(p.Sort, columns)
+		if err != nil {
+			return nil, err
+		}
+
+		return q.OrderExpr(order).Offset(p.Index * p.Size).Limit(p.Size + 1), nil
+	}
+}
+
+// parseSort turns a sort parameter such as "-protein,name" into an ORDER BY
+// list, rejecting columns that are not whitelisted. The row id is appended as
+// a tiebreaker so equal values keep a stable order between pages.
+func parseSort(sort string, columns sortColumns) (string, error) {
+	var order []string
+	seen := map[string]bool{}
+
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		direction := "ASC"
+
+		switch {
+		case strings.HasPrefix(field, "-"):
+			field = field[1:]
+			direction = "DESC NULLS LAST"
+		case strings.HasPrefix(field, "+"):
+			field = field[1:]
+		}
+
+		column, ok := columns[field]
+		if !ok {
+			return "", fmt.Errorf("cannot sort by %q", field)
+		}
+
+		if seen[field] {
+			return "", fmt.Errorf("duplicate sort field %q", field)
+		}
+		seen[field] = true
+
+		order = append(order, column+" "+direction)
+	}
+
+	order = append(order, "?TableAlias.id ASC")
+	return strings.Join(order, ", "), nil
+}
+
+func (p Page) applyKeyset(q *orm.Query) (*orm.Query, error) {
+	switch {
+	case p.Cursor == nil:
+		q = q.OrderExpr("?TableAlias.timestamp DESC, ?TableAlias.id DESC").
+			Offset(p.Index * p.Size)
+	case p.Cursor.Backward:
+		q = q.Where("(?TableAlias.timestamp, ?TableAlias.id) > (?, ?)", p.Cursor.Timestamp, p.Cursor.Id).
+			OrderExpr("?TableAlias.timestamp ASC, ?TableAlias.id ASC")
+	default:
+		q = q.Where("(?TableAlias.timestamp, ?TableAlias.id) < (?, ?)", p.Cursor.Timestamp, p.Cursor.Id).
+			OrderExpr("?TableAlias.timestamp DESC, ?TableAlias.id DESC")
+	}
+
+	return q.Limit(p.Size + 1), nil
+}
+
+// paginate trims the look-ahead row fetched by Page.apply, restores newest
+// first order for backward pages and builds the cursors around the result.
+// Cursors are only issued for the default order, which is what they encode.
+func paginate[T keyed](rows []T, page Page) ([]T, Cursors) {
+	var cursors Cursors
+
+	hasMore := len(rows) > page.Size
+	if hasMore {
+		rows = rows[:page.Size]
+	}
+
+	backward := page.Cursor != nil && page.Cursor.Backward
+	if backward {
+		slices.Reverse(rows)
+	}
+
+	if len(rows) == 0 || page.Sort != "" {
+		return rows, cursors
+	}
+
+	first := rows[0].cursor()
+	first.Backward = true
+	last := rows[len(rows)-1].cursor()
+
+	switch {
+	case page.Cursor == nil:
+		if hasMore {
+			cursors.Next = EncodeCursor(last)
+		}
+		if page.Index > 0 {
+			cursors.Prev = EncodeCursor(first)
+		}
+	case backward:
+		cursors.Next = EncodeCursor(last)
+		if hasMore {
+			cursors.Prev = EncodeCursor(first)
+		}
+	default:
+		if hasMore {
+			cursors.Next = EncodeCursor(last)
+		}
+		cursors.Prev = EncodeCursor(first)
+	}
+
+	return rows, cursors
+}
@@ -0,0 +1,277 @@
+package data
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type testRow struct {
+	Timestamp time.Time
+	Id        uuid.UUID
+}
+
+func (r testRow) cursor() Cursor {
+	return Cursor{Timestamp: r.Timestamp, Id: r.Id}
+}
+
+// testRows returns n rows, newest first, as the default order lists them.
+func testRows(n int) []testRow {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	result := make([]testRow, n)
+	for i := range result {
+		result[i] = testRow{Timestamp: start.Add(time.Duration(n-i) * time.Minute), Id: uuid.New()}
+	}
+
+	return result
+}
+
+func reversed(rows []testRow) []testRow {
+	result := make([]testRow, len(rows))
+	for i, r := range rows {
+		result[len(rows)-1-i] = r
+	}
+
+	return result
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{"forward", Cursor{Timestamp: time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC), Id: uuid.New()}},
+		{"backward", Cursor{Timestamp: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), Id: uuid.New(), Backward: true}},
+		{"before epoch", Cursor{Timestamp: time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC), Id: uuid.New()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeCursor(EncodeCursor(tt.cursor))
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+
+			if !got.Timestamp.Equal(tt.cursor.Timestamp) || got.Id != tt.cursor.Id || got.Backward != tt.cursor.Backward {
+				t.Errorf("got %+v, want %+v", *got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestCursorTruncatesToMicroseconds(t *testing.T) {
+	cursor := Cursor{Timestamp: time.Date(2024, 3, 1, 0, 0, 0, 123456789, time.UTC), Id: uuid.New()}
+
+	got, err := DecodeCursor(EncodeCursor(cursor))
+	if err != nil {
+		t.Fatalf("DecodeCursor: %v", err)
+	}
+
+	want := cursor.Timestamp.Truncate(time.Microsecond)
+	if !got.Timestamp.Equal(want) {
+		t.Errorf("got %v, want %v", got.Timestamp, want)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"not base64", "not a cursor!"},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("nope"))},
+		{"missing id", base64.RawURLEncoding.EncodeToString([]byte(`{"t":1}`))},
+		{"nil id", base64.RawURLEncoding.EncodeToString([]byte(`{"t":1,"id":"` + uuid.Nil.String() + `"}`))},
+		{"bad id", base64.RawURLEncoding.EncodeToString([]byte(`{"t":1,"id":"nope"}`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCursor(tt.value)
+			if err == nil {
+				t.Errorf("DecodeCursor(%q) succeeded", tt.value)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	all := testRows(4)
+
+	tests := []struct {
+		name     string
+		rows     []testRow
+		page     Page
+		want     []testRow
+		wantNext *testRow
+		wantPrev *testRow
+	}{
+		{
+			name: "empty",
+			rows: nil,
+			page: Page{Size: 3},
+			want: nil,
+		},
+		{
+			name:     "first page with more",
+			rows:     all,
+			page:     Page{Size: 3},
+			want:     all[:3],
+			wantNext: &all[2],
+		},
+		{
+			name: "first page exactly full",
+			rows: all[:3],
+			page: Page{Size: 3},
+			want: all[:3],
+		},
+		{
+			name:     "offset page",
+			rows:     all[2:],
+			page:     Page{Index: 1, Size: 2},
+			want:     all[2:],
+			wantPrev: &all[2],
+		},
+		{
+			name:     "forward cursor with more",
+			rows:     all[1:],
+			page:     Page{Size: 2, Cursor: &Cursor{}},
+			want:     all[1:3],
+			wantNext: &all[2],
+			wantPrev: &all[1],
+		},
+		{
+			name:     "forward cursor on last page",
+			rows:     all[3:],
+			page:     Page{Size: 2, Cursor: &Cursor{}},
+			want:     all[3:],
+			wantPrev: &all[3],
+		},
+		{
+			name:     "backward cursor with more",
+			rows:     reversed(all[:3]),
+			page:     Page{Size: 2, Cursor: &Cursor{Backward: true}},
+			want:     []testRow{all[1], all[2]},
+			wantNext: &all[2],
+			wantPrev: &all[1],
+		},
+		{
+			name:     "backward cursor on first page",
+			rows:     reversed(all[:2]),
+			page:     Page{Size: 2, Cursor: &Cursor{Backward: true}},
+			want:     all[:2],
+			wantNext: &all[1],
+		},
+		{
+			name: "sorted",
+			rows: all,
+			page: Page{Size: 3, Sort: "name"},
+			want: all[:3],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cursors := paginate(tt.rows, tt.page)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v rows, want %v", len(got), len(tt.want))
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("row %v: got %v, want %v", i, got[i].Id, tt.want[i].Id)
+				}
+			}
+
+			checkCursor(t, "next", cursors.Next, tt.wantNext, false)
+			checkCursor(t, "prev", cursors.Prev, tt.wantPrev, true)
+		})
+	}
+}
+
+func checkCursor(t *testing.T, name, token string, want *testRow, backward bool) {
+	t.Helper()
+
+	if want == nil {
+		if token != "" {
+			t.Errorf("%v: got a cursor, want none", name)
+		}
+		return
+	}
+
+	if token == "" {
+		t.Errorf("%v: got no cursor, want one", name)
+		return
+	}
+
+	cursor, err := DecodeCursor(token)
+	if err != nil {
+		t.Fatalf("%v: DecodeCursor: %v", name, err)
+	}
+
+	if cursor.Id != want.Id || cursor.Backward != backward {
+		t.Errorf("%v: got %+v, want row %v with backward %v", name, *cursor, want.Id, backward)
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	columns := sortColumns{
+		"name":    "f.name",
+		"protein": "f.protein",
+	}
+
+	tests := []struct {
+		name    string
+		sort    string
+		want    string
+		wantErr bool
+	}{
+		{name: "ascending", sort: "name", want: "f.name ASC, ?TableAlias.id ASC"},
+		{name: "explicit ascending", sort: "+name", want: "f.name ASC, ?TableAlias.id ASC"},
+		{name: "descending", sort: "-protein", want: "f.protein DESC NULLS LAST, ?TableAlias.id ASC"},
+		{name: "several", sort: "-protein,name", want: "f.protein DESC NULLS LAST, f.name ASC, ?TableAlias.id ASC"},
+		{name: "spaces", sort: " -protein , name ", want: "f.protein DESC NULLS LAST, f.name ASC, ?TableAlias.id ASC"},
+		{name: "unknown column", sort: "kcal", wantErr: true},
+		{name: "sql", sort: "name; DROP TABLE core.food", wantErr: true},
+		{name: "column expression", sort: "f.name", wantErr: true},
+		{name: "duplicate", sort: "name,-name", wantErr: true},
+		{name: "empty field", sort: "name,", wantErr: true},
+		{name: "sign only", sort: "-", wantErr: true},
+		{name: "case sensitive", sort: "Name", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSort(tt.sort, columns)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseSort(%q) = %q, want an error", tt.sort, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseSort(%q): %v", tt.sort, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseSort(%q) = %q, want %q", tt.sort, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageApplyRejectsSortWithCursor(t *testing.T) {
+	page := Page{Size: 10, Sort: "name", Cursor: &Cursor{}}
+
+	_, err := page.apply(sortColumns{"name": "f.name"})(nil)
+	if err == nil {
+		t.Error("apply succeeded, want an error")
+	}
+}
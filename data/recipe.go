@@ -0,0 +1,309 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type RecipeDto struct {
+	tableName   struct{}              `pg:"core.recipe,alias:r"`
+	Id          uuid.UUID             `json:"id" db:"id"`
+	Timestamp   time.Time             `json:"timestamp" db:"timestamp"`
+	User        uuid.UUID             `json:"user" db:"user"`
+	Name        string                `json:"name" db:"name" validate:"min=3"`
+	Servings    int                   `json:"servings" db:"servings" validate:"min=1"`
+	Ingredients []RecipeIngredientDto `json:"ingredients" pg:"-" validate:"min=1,dive"`
+	Version     int                   `json:"version" db:"version"`
+	Trash
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type RecipeIngredientDto struct {
+	tableName struct{}  `pg:"core.recipe_ingredient,alias:ri"`
+	Id        uuid.UUID `json:"id" db:"id"`
+	Recipe    uuid.UUID `json:"recipe" db:"recipe"`
+	Food      uuid.UUID `json:"food" db:"food" validate:"required"`
+	Grams     float32   `json:"grams" db:"grams" validate:"gt=0"`
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type RecipeTableDto struct {
+	tableName   struct{}                   `pg:"core.recipe,alias:r"`
+	Id          uuid.UUID                  `json:"id" pg:"id"`
+	Timestamp   time.Time                  `json:"timestamp" pg:"timestamp"`
+	UserId      uuid.UUID                  `json:"-" pg:"user"`
+	User        *AuthDto                   `json:"user" pg:"fk:user,rel:has-one"`
+	Name        string                     `json:"name" pg:"name"`
+	Servings    int                        `json:"servings" pg:"servings"`
+	Ingredients []RecipeIngredientTableDto `json:"ingredients" pg:"rel:has-many,join_fk:recipe"`
+	Total       NutritionDto               `json:"total" pg:"-"`
+	PerServing  NutritionDto               `json:"perServing" pg:"-"`
+	Version     int                        `json:"version" pg:"version"`
+	Trash
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type RecipeIngredientTableDto struct {
+	tableName struct{}     `pg:"core.recipe_ingredient,alias:ri"`
+	Id        uuid.UUID    `json:"id" pg:"id"`
+	RecipeId  uuid.UUID    `json:"-" pg:"recipe"`
+	FoodId    uuid.UUID    `json:"-" pg:"food"`
+	Food      *FoodDto     `json:"food" pg:"fk:food,rel:has-one"`
+	Grams     float32      `json:"grams" pg:"grams"`
+	Nutrition NutritionDto `json:"nutrition" pg:"-"`
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type RecipeFilterDto struct {
+	Id   uuid.UUID `json:"id" db:"id"`
+	Name string    `json:"name" db:"name"`
+	User uuid.UUID `json:"user" db:"user"`
+}
+
+var recipeSortColumns = sortColumns{
+	"name":      "r.name",
+	"timestamp": "r.timestamp",
+	"servings":  "r.servings",
+}
+
+func (r RecipeTableDto) cursor() Cursor {
+	return Cursor{Timestamp: r.Timestamp, Id: r.Id}
+}
+
+// computeNutrition derives the ingredient, total and per serving values from
+// the per 100 g values of the referenced foods.
+func (r *RecipeTableDto) computeNutrition() {
+	r.Total = NutritionDto{}
+
+	for i := range r.Ingredients {
+		ingredient := &r.Ingredients[i]
+		if ingredient.Food == nil {
+			continue
+		}
+
+		ingredient.Nutrition = ingredient.Food.Nutrition().ForGrams(ingredient.Grams)
+		r.Total = r.Total.Add(ingredient.Nutrition)
+	}
+
+	r.PerServing = r.Total
+	if r.Servings > 0 {
+		r.PerServing = r.Total.Scale(1 / float32(r.Servings))
+	}
+}
+
+func NewRecipe(user uuid.UUID, name string, servings int, ingredients []RecipeIngredientDto) (*RecipeDto, error) {
+	validate := validator.New()
+
+	recipe := &RecipeDto{
+		User:        user,
+		Name:        name,
+		Servings:    servings,
+		Ingredients: ingredients,
+	}
+
+	err := validate.Struct(recipe)
+	if err != nil {
+		return nil, err
+	}
+
+	return recipe, nil
+}
+
+func NewRecipeFilterDto(id uuid.UUID, name string, user uuid.UUID) (*RecipeFilterDto, error) {
+	validate := validator.New()
+
+	filter := &RecipeFilterDto{
+		Id:   id,
+		Name: name,
+		User: user,
+	}
+
+	err := validate.Struct(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, nil
+}
+
+func (f RecipeFilterDto) apply(q *orm.Query) (*orm.Query, error) {
+	if f.Id != uuid.Nil {
+		q = q.Where("r.id = ?", f.Id)
+	}
+
+	if f.Name != "" {
+		q = q.Where("r.name ILIKE ?", containsPattern(f.Name))
+	}
+
+	if f.User != uuid.Nil {
+		q = q.Where(`r."user" = ?`, f.User)
+	}
+
+	return q, nil
+}
+
+func (d *DataConn) ListRecipes(filter RecipeFilterDto, page Page) ([]RecipeTableDto, Cursors, error) {
+	var recipes []RecipeTableDto
+
+	err := d.DB.Model(&recipes).
+		Relation("User").
+		Relation("Ingredients.Food").
+		Apply(alive).
+		Apply(filter.apply).
+		Apply(page.apply(recipeSortColumns)).
+		Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	recipes, cursors := paginate(recipes, page)
+	for i := range recipes {
+		recipes[i].computeNutrition()
+	}
+
+	return recipes, cursors, nil
+}
+
+func (d *DataConn) CountRecipes(filter RecipeFilterDto) (int, error) {
+	var recipes []RecipeDto
+
+	count, err := d.DB.Model(&recipes).Apply(alive).Apply(filter.apply).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (d *DataConn) GetRecipeById(id uuid.UUID) (RecipeTableDto, error) {
+	var recipe RecipeTableDto
+
+	err := d.DB.Model(&recipe).
+		Relation("User").
+		Relation("Ingredients.Food").
+		Where("r.id = ?", id).
+		Apply(alive).
+		Select()
+
+	if err != nil {
+		return recipe, err
+	}
+
+	recipe.computeNutrition()
+	return recipe, nil
+}
+
+func (d *DataConn) CreateRecipe(dto RecipeDto) error {
+	return d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		_, err := tx.Model(&dto).Insert()
+		if err != nil {
+			return err
+		}
+
+		return insertRecipeIngredients(tx, dto.Id, dto.Ingredients)
+	})
+}
+
+func (d *DataConn) EditRecipe(actor Actor, id uuid.UUID, version int, name string, servings int, ingredients []RecipeIngredientDto) error {
+	return d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		err := lockVersion(tx.Model((*RecipeDto)(nil)).Where("id = ?", id).Apply(actor.owns), version)
+		if err != nil {
+			return err
+		}
+
+		var recipe RecipeDto
+		res, err := tx.Model(&recipe).
+			Set("name = ?", name).
+			Set("servings = ?", servings).
+			Where("id = ?", id).
+			Apply(alive).
+			Apply(actor.owns).
+			Update()
+
+		err = ownedResult(tx, &recipe, id, res, err)
+		if err != nil {
+			return err
+		}
+
+		var ingredient RecipeIngredientDto
+		_, err = tx.Model(&ingredient).Where("recipe = ?", id).Delete()
+		if err != nil {
+			return err
+		}
+
+		return insertRecipeIngredients(tx, id, ingredients)
+	})
+}
+
+func (d *DataConn) DeleteRecipe(actor Actor, id uuid.UUID, version int) error {
+	return d.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		err := lockVersion(tx.Model((*RecipeDto)(nil)).Where("id = ?", id).Apply(actor.owns), version)
+		if err != nil {
+			return err
+		}
+
+		var recipe RecipeDto
+		res, err := moveToTrash(tx.Model(&recipe).Where("id = ?", id).Apply(actor.owns), actor.Id)
+		return ownedResult(tx, &recipe, id, res, err)
+	})
+}
+
+// ListRecipeTrash lists the recipes in the trash that actor may restore.
+func (d *DataConn) ListRecipeTrash(actor Actor, page Page) ([]RecipeTableDto, Cursors, error) {
+	var recipes []RecipeTableDto
+
+	err := d.DB.Model(&recipes).
+		Relation("User").
+		Relation("Ingredients.Food").
+		Apply(trashed).
+		Apply(actor.owns).
+		Apply(page.apply(withDeletedAt(recipeSortColumns))).
+		Select()
+	if err != nil {
+		return nil, Cursors{}, err
+	}
+
+	recipes, cursors := paginate(recipes, page)
+	for i := range recipes {
+		recipes[i].computeNutrition()
+	}
+
+	return recipes, cursors, nil
+}
+
+func (d *DataConn) CountRecipeTrash(actor Actor) (int, error) {
+	var recipes []RecipeDto
+
+	count, err := d.DB.Model(&recipes).Apply(trashed).Apply(actor.owns).Count()
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (d *DataConn) RestoreRecipe(actor Actor, id uuid.UUID) error {
+	var recipe RecipeDto
+	res, err := restoreFromTrash(d.DB.Model(&recipe).Where("id = ?", id).Apply(actor.owns))
+	return restoredResult(&d.DB, &recipe, id, res, err)
+}
+
+func insertRecipeIngredients(tx *pg.Tx, recipe uuid.UUID, ingredients []RecipeIngredientDto) error {
+	if len(ingredients) == 0 {
+		return nil
+	}
+
+	for i := range ingredients {
+		ingredients[i].Id = uuid.Nil
+		ingredients[i].Recipe = recipe
+	}
+
+	_, err := tx.Model(&ingredients).Insert()
+	return err
+}
@@ -0,0 +1,188 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/adamelfsborg-code/food/culinary/unit"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type FoodServingDto struct {
+	tableName   struct{}  `pg:"core.food_serving,alias:fs"`
+	Id          uuid.UUID `json:"id" db:"id"`
+	Food        uuid.UUID `json:"food" db:"food"`
+	Label       string    `json:"label" db:"label" validate:"min=1,max=50"`
+	Grams       float32   `json:"grams" db:"grams" validate:"gt=0"`
+	Milliliters *float32  `json:"milliliters" db:"milliliters" validate:"omitempty,gt=0"`
+}
+
+// FoodNutritionDto is the nutrition of a food at a requested quantity.
+type FoodNutritionDto struct {
+	Food      uuid.UUID    `json:"food"`
+	Quantity  float32      `json:"quantity"`
+	Unit      string       `json:"unit"`
+	Grams     float32      `json:"grams"`
+	Nutrition NutritionDto `json:"nutrition"`
+}
+
+func NewFoodServing(food uuid.UUID, label string, grams float32, milliliters *float32) (*FoodServingDto, error) {
+	validate := validator.New()
+
+	serving := &FoodServingDto{
+		Food:        food,
+		Label:       strings.TrimSpace(label),
+		Grams:       grams,
+		Milliliters: milliliters,
+	}
+
+	err := validate.Struct(serving)
+	if err != nil {
+		return nil, err
+	}
+
+	if unit.IsKnown(serving.Label) {
+		return nil, fmt.Errorf("serving label %q is reserved for a unit", serving.Label)
+	}
+
+	return serving, nil
+}
+
+// density returns grams per millilitre from the first serving that has a
+// volume, or 0 when none does.
+func density(servings []FoodServingDto) float64 {
+	for _, serving := range servings {
+		if serving.Milliliters != nil && *serving.Milliliters > 0 {
+			return float64(serving.Grams) / float64(*serving.Milliliters)
+		}
+	}
+
+	return 0
+}
+
+// servingGrams converts quantity in unitName to grams, trying the food's own
+// servings before the mass and volume units of package unit.
+func servingGrams(servings []FoodServingDto, quantity float64, unitName string) (float64, error) {
+	for _, serving := range servings {
+		if strings.EqualFold(serving.Label, strings.TrimSpace(unitName)) {
+			return quantity * float64(serving.Grams), nil
+		}
+	}
+
+	return unit.ToGrams(quantity, unitName, density(servings))
+}
+
+func (d *DataConn) ListFoodServings(food uuid.UUID) ([]FoodServingDto, error) {
+	servings := []FoodServingDto{}
+
+	err := d.DB.Model(&servings).Where("fs.food = ?", food).Order("fs.grams ASC").Select()
+	if err != nil {
+		return nil, err
+	}
+
+	return servings, nil
+}
+
+// CreateFoodServing adds a serving to a food actor may edit. Servings are
+// part of the food, so it only goes ahead while the food is at version.
+func (d *DataConn) CreateFoodServing(actor Actor, dto FoodServingDto, version int) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		err := touchOwnedFood(tx, actor, dto.Food, version)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Model(&dto).Insert()
+
+		pgErr, ok := err.(pg.Error)
+		if ok && pgErr.Field('C') == "23505" {
+			return conflict("serving already exists")
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return changes.created("serving", dto.Id, dto)
+	})
+}
+
+// DeleteFoodServing is CreateFoodServing for removing a serving.
+func (d *DataConn) DeleteFoodServing(actor Actor, food, id uuid.UUID, version int) error {
+	return d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		err := touchOwnedFood(tx, actor, food, version)
+		if err != nil {
+			return err
+		}
+
+		var serving FoodServingDto
+		res, err := tx.Model(&serving).Where("id = ?", id).Where("food = ?", food).Returning("*").Delete()
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return pg.ErrNoRows
+		}
+
+		return changes.deleted("serving", id, serving)
+	})
+}
+
+// touchOwnedFood bumps the version of a live food actor may edit, whose
+// representation includes its servings, and so locks it for the rest of tx:
+// it cannot be deleted while rows hanging off it are written. It fails the
+// way ownedResult does when actor may not edit the food, and with
+// ErrPreconditionFailed when it was not at version.
+func touchOwnedFood(tx *pg.Tx, actor Actor, id uuid.UUID, version int) error {
+	var food FoodDto
+	res, err := tx.Model(&food).
+		Set("version = version"). // core.bump_version does the bump
+		Where("f.id = ?", id).
+		Apply(alive).
+		Apply(actor.owns).
+		Returning("version").
+		Update()
+	err = ownedResult(tx, &food, id, res, err)
+	if err != nil {
+		return err
+	}
+
+	// The returned version is the bumped one.
+	return expectVersion(food.Version-1, version)
+}
+
+func (d *DataConn) CalculateFoodNutrition(id uuid.UUID, quantity float32, unitName string) (FoodNutritionDto, error) {
+	result := FoodNutritionDto{
+		Food:     id,
+		Quantity: quantity,
+		Unit:     unitName,
+	}
+
+	if quantity <= 0 {
+		return result, errors.New("quantity must be positive")
+	}
+
+	food, err := d.GetFoodById(id)
+	if err != nil {
+		return result, err
+	}
+
+	servings, err := d.ListFoodServings(id)
+	if err != nil {
+		return result, err
+	}
+
+	grams, err := servingGrams(servings, float64(quantity), unitName)
+	if err != nil {
+		return result, err
+	}
+
+	result.Grams = float32(grams)
+	result.Nutrition = food.Nutrition().ForGrams(result.Grams)
+	return result, nil
+}
@@ -0,0 +1,153 @@
+package data
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+)
+
+// Trash is embedded in the rows that are moved to the trash rather than
+// deleted. Lists, counts and lookups leave them out until they are restored
+// or purged.
+type Trash struct {
+	DeletedAt *time.Time `json:"deletedAt,omitempty" pg:"deleted_at"`
+	DeletedBy *uuid.UUID `json:"deletedBy,omitempty" pg:"deleted_by"`
+}
+
+func (t Trash) InTrash() bool {
+	return t.DeletedAt != nil
+}
+
+// trashConflict is the error for changing a row in the trash.
+func trashConflict(id any) error {
+	return conflict("%v is in the trash, restore it first", id)
+}
+
+// alive leaves out the rows in the trash.
+func alive(q *orm.Query) (*orm.Query, error) {
+	return q.Where("?TableAlias.deleted_at IS NULL"), nil
+}
+
+// trashed selects only the rows in the trash.
+func trashed(q *orm.Query) (*orm.Query, error) {
+	return q.Where("?TableAlias.deleted_at IS NOT NULL"), nil
+}
+
+// withDeletedAt adds sorting by the time rows were moved to the trash to the
+// sort columns of a list.
+func withDeletedAt(columns sortColumns) sortColumns {
+	columns = maps.Clone(columns)
+	columns["deletedAt"] = "?TableAlias.deleted_at"
+	return columns
+}
+
+// hasTrash reports whether the table of q keeps a trash.
+func hasTrash(q *orm.Query) bool {
+	return q.TableModel().Table().HasField("deleted_at")
+}
+
+// moveToTrash moves the live rows matched by q to the trash on behalf of
+// actor and reads them back into the model of q.
+func moveToTrash(q *orm.Query, actor uuid.UUID) (orm.Result, error) {
+	return q.
+		Set("deleted_at = now()").
+		Set("deleted_by = ?", actor).
+		Apply(alive).
+		Returning("*").
+		Update()
+}
+
+// restoreFromTrash takes the rows matched by q out of the trash and reads
+// them back into the model of q.
+func restoreFromTrash(q *orm.Query) (orm.Result, error) {
+	res, err := q.
+		Set("deleted_at = NULL").
+		Set("deleted_by = NULL").
+		Apply(trashed).
+		Returning("*").
+		Update()
+
+	pgErr, ok := err.(pg.Error)
+	if ok && pgErr.Field('C') == "23505" {
+		return nil, conflict("restoring would duplicate a live row")
+	}
+
+	return res, err
+}
+
+// restoredResult checks the outcome of restoreFromTrash limited by
+// Actor.owns: pg.ErrNoRows when nothing with id is in the trash,
+// ErrForbidden when it belongs to someone else.
+func restoredResult(db orm.DB, model any, id uuid.UUID, res orm.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() > 0 {
+		return nil
+	}
+
+	exists, err := db.Model(model).Where("?TableAlias.id = ?", id).Apply(trashed).Exists()
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return pg.ErrNoRows
+	}
+
+	return ErrForbidden
+}
+
+// purgeTables lists the tables with a trash in the order they are purged,
+// rows before the rows they reference. inUse matches the rows of t that are
+// still referenced, which are kept until whatever references them is gone.
+var purgeTables = []struct {
+	table string
+	inUse string
+}{
+	{table: "core.diary_entry"},
+	{table: "core.goal"},
+	{table: "core.recipe"},
+	{
+		table: "core.food",
+		inUse: "EXISTS (SELECT 1 FROM core.diary_entry AS r WHERE r.food = t.id) OR " +
+			"EXISTS (SELECT 1 FROM core.recipe_ingredient AS r WHERE r.food = t.id)",
+	},
+	{table: "core.food_type", inUse: "EXISTS (SELECT 1 FROM core.food AS r WHERE r.food_type = t.id)"},
+	{table: "core.brand", inUse: "EXISTS (SELECT 1 FROM core.food AS r WHERE r.brand = t.id)"},
+	{table: "core.category", inUse: "EXISTS (SELECT 1 FROM core.food_type AS r WHERE r.category = t.id)"},
+}
+
+// PurgeTrash deletes the rows moved to the trash before cutoff for good and
+// returns how many it deleted per table. Rows still referenced from outside
+// the trash are kept for a later run.
+func (d *DataConn) PurgeTrash(ctx context.Context, cutoff time.Time) (map[string]int, error) {
+	purged := map[string]int{}
+
+	err := d.DB.RunInTransaction(ctx, func(tx *pg.Tx) error {
+		for _, p := range purgeTables {
+			inUse := pg.Safe("false")
+			if p.inUse != "" {
+				inUse = pg.Safe(p.inUse)
+			}
+
+			res, err := tx.ExecContext(ctx,
+				`DELETE FROM ? AS t WHERE t.deleted_at < ? AND NOT (?)`,
+				pg.Safe(p.table), cutoff, inUse)
+			if err != nil {
+				return err
+			}
+
+			purged[p.table] = res.RowsAffected()
+		}
+
+		return nil
+	})
+
+	return purged, err
+}
@@ -0,0 +1,290 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/google/uuid"
+)
+
+const (
+	UserDeleted = "user.deleted"
+	UserMerged  = "user.merged"
+)
+
+// DeletedUser owns the catalogue rows of users deleted in the auth service,
+// see migration 0010_user_event.
+var DeletedUser = uuid.MustParse("6d1f5c3e-0a47-4b8e-9c2d-7e51f0a3b9d4")
+
+// personalTables only mean something to their owner.
+var personalTables = []string{"core.recipe", "core.diary_entry", "core.goal"}
+
+// UserEventDto is a user lifecycle event of the auth service. User is the
+// deleted user or the one merged away, Into the user it was merged into.
+type UserEventDto struct {
+	Id   string    `json:"id"`
+	User uuid.UUID `json:"user"`
+	Into uuid.UUID `json:"into"`
+}
+
+//lint:ignore U1000 Ignore unused function temporarily for debugging
+type userEventRow struct {
+	tableName struct{}  `pg:"core.user_event"`
+	Key       string    `pg:"key,pk"`
+	Type      string    `pg:"type"`
+	User      uuid.UUID `pg:"user"`
+}
+
+// ParseUserEvent decodes and checks a payload of eventType. An error means
+// the event can never be applied.
+func ParseUserEvent(eventType string, payload []byte) (UserEventDto, error) {
+	var event UserEventDto
+
+	err := json.Unmarshal(payload, &event)
+	if err != nil {
+		return event, err
+	}
+
+	if event.User == uuid.Nil {
+		return event, fmt.Errorf("%v without user", eventType)
+	}
+
+	if event.User == DeletedUser {
+		return event, fmt.Errorf("%v of the deleted user placeholder", eventType)
+	}
+
+	switch eventType {
+	case UserDeleted:
+	case UserMerged:
+		if event.Into == uuid.Nil || event.Into == event.User {
+			return event, fmt.Errorf("%v needs another user to merge into", eventType)
+		}
+	default:
+		return event, fmt.Errorf("unknown user event %v", eventType)
+	}
+
+	return event, nil
+}
+
+// ApplyUserEvent moves everything owned by event.User in one transaction. A
+// deleted user's catalogue rows go to DeletedUser and their personal rows are
+// removed; a merged user's rows all go to event.Into. key identifies the
+// event, and it returns false without changing anything when an event with
+// the same key was applied before. Every catalogue row it changes is recorded
+// as updated, with key as the request id, so consumers and the history see
+// the new owner. Changes for a merge are recorded by event.User. Those for a
+// deletion are recorded by DeletedUser, and the deleted user's id is left out
+// of their snapshots too, so the new audit rows and events do not name them;
+// audit rows written before keep the ids of the users who made them. Personal
+// rows are not part of the event stream, and change silently as they do
+// everywhere else.
+func (d *DataConn) ApplyUserEvent(key, eventType string, event UserEventDto) (bool, error) {
+	applied := false
+
+	actor := Actor{Id: event.User, RequestId: key}
+	if eventType == UserDeleted {
+		actor.Id = DeletedUser
+	}
+
+	err := d.write(actor, func(tx *pg.Tx, changes *changeSet) error {
+		row := userEventRow{Key: key, Type: eventType, User: event.User}
+		res, err := tx.Model(&row).OnConflict("DO NOTHING").Insert()
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return nil
+		}
+
+		switch eventType {
+		case UserDeleted:
+			err = deleteUserRows(tx, changes, event.User)
+		case UserMerged:
+			err = mergeUserRows(tx, changes, event.User, event.Into)
+		default:
+			err = fmt.Errorf("unknown user event %v", eventType)
+		}
+		if err != nil {
+			return err
+		}
+
+		applied = true
+		return nil
+	})
+
+	return applied, err
+}
+
+// PurgeUserEvents forgets the events applied before cutoff and returns how
+// many it forgot. cutoff has to lie further back than JetStream may still
+// redeliver an event, or a redelivery would be applied twice.
+func (d *DataConn) PurgeUserEvents(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := d.DB.ModelContext(ctx, (*userEventRow)(nil)).Where("processed_at < ?", cutoff).Delete()
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+func deleteUserRows(tx *pg.Tx, changes *changeSet, user uuid.UUID) error {
+	for _, table := range personalTables {
+		_, err := tx.Exec(`DELETE FROM ? WHERE "user" = ?`, pg.Safe(table), user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return reassignCatalogue(tx, changes, user, DeletedUser)
+}
+
+func mergeUserRows(tx *pg.Tx, changes *changeSet, from, into uuid.UUID) error {
+	// A user has one live goal per day, and the one of the user merged into
+	// wins.
+	_, err := tx.Exec(`
+		DELETE FROM core.goal g
+		WHERE g."user" = ?0 AND g.deleted_at IS NULL
+		AND EXISTS (
+			SELECT 1 FROM core.goal o
+			WHERE o."user" = ?1 AND o.deleted_at IS NULL AND o.effective_from = g.effective_from
+		)`,
+		from, into)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range personalTables {
+		_, err = tx.Exec(`UPDATE ? SET "user" = ? WHERE "user" = ?`, pg.Safe(table), into, from)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`UPDATE ? SET deleted_by = ? WHERE deleted_by = ?`, pg.Safe(table), into, from)
+		if err != nil {
+			return err
+		}
+	}
+
+	return reassignCatalogue(tx, changes, from, into)
+}
+
+// reassign hands the rows of q owned or moved to the trash by from over to
+// into, bumping their version.
+func reassign(q *orm.Query, from, into uuid.UUID) error {
+	_, err := q.
+		Set(`"user" = CASE WHEN "user" = ?0 THEN ?1 ELSE "user" END`, from, into).
+		Set(`deleted_by = CASE WHEN deleted_by = ?0 THEN ?1 ELSE deleted_by END`, from, into).
+		Where(`"user" = ?0 OR deleted_by = ?0`, from).
+		Update()
+	return err
+}
+
+// ownedBy limits a query to the rows owned or moved to the trash by user.
+func ownedBy(user uuid.UUID) func(q *orm.Query) (*orm.Query, error) {
+	return func(q *orm.Query) (*orm.Query, error) {
+		return q.Where(`?TableAlias."user" = ?0 OR ?TableAlias.deleted_by = ?0`, user), nil
+	}
+}
+
+// reassignCatalogue hands the catalogue rows owned or moved to the trash by
+// from over to into, recording each of them as updated. Catalogue rows are
+// shared with everyone and outlive their creator.
+func reassignCatalogue(tx *pg.Tx, changes *changeSet, from, into uuid.UUID) error {
+	var foods []uuid.UUID
+	err := tx.Model((*FoodDto)(nil)).Column("f.id").Apply(ownedBy(from)).Order("f.id").For("UPDATE").Select(&foods)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range foods {
+		before, err := loadFood(tx, id, false)
+		if err != nil {
+			return err
+		}
+
+		err = reassign(tx.Model((*FoodDto)(nil)).Where("id = ?", id), from, into)
+		if err != nil {
+			return err
+		}
+
+		after, err := loadFood(tx, id, false)
+		if err != nil {
+			return err
+		}
+
+		err = recordReassigned(changes, "food", id, before, after, from, into)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = reassignRows(tx, changes, "brand", from, into, func(b BrandDto) uuid.UUID { return b.Id })
+	if err != nil {
+		return err
+	}
+
+	err = reassignRows(tx, changes, "category", from, into, func(c CategoryDto) uuid.UUID { return c.Id })
+	if err != nil {
+		return err
+	}
+
+	return reassignRows(tx, changes, "foodtype", from, into, func(ft FoodTypeDto) uuid.UUID { return ft.Id })
+}
+
+// reassignRows is reassignCatalogue for the rows of T, whose snapshots are
+// the rows themselves.
+func reassignRows[T any](tx *pg.Tx, changes *changeSet, entity string, from, into uuid.UUID, id func(T) uuid.UUID) error {
+	var rows []T
+	err := tx.Model(&rows).Apply(ownedBy(from)).OrderExpr("?TableAlias.id").For("UPDATE").Select()
+	if err != nil {
+		return err
+	}
+
+	for _, before := range rows {
+		var after T
+		err := reassign(tx.Model(&after).Where("?TableAlias.id = ?", id(before)).Returning("*"), from, into)
+		if err != nil {
+			return err
+		}
+
+		err = recordReassigned(changes, entity, id(before), before, after, from, into)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordReassigned records a row handed from one user to another as updated.
+// When it went to DeletedUser, from is replaced by DeletedUser in the owner
+// fields of before, so the change does not name the deleted user.
+func recordReassigned(changes *changeSet, entity string, id uuid.UUID, before, after any, from, into uuid.UUID) error {
+	if into != DeletedUser {
+		return changes.updated(entity, id, before, after)
+	}
+
+	raw, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+
+	var masked map[string]any
+	err = json.Unmarshal(raw, &masked)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range []string{"user", "deletedBy"} {
+		if masked[field] == from.String() {
+			masked[field] = DeletedUser.String()
+		}
+	}
+
+	return changes.updated(entity, id, masked, after)
+}
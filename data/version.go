@@ -0,0 +1,50 @@
+package data
+
+import (
+	"errors"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+)
+
+// AnyVersion is the version expected by writes that are not conditional,
+// such as those sent without If-Match.
+const AnyVersion = 0
+
+// ErrPreconditionFailed is returned when a conditional write finds its row at
+// another version than the one it expects.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// expectVersion fails with ErrPreconditionFailed unless current is version.
+func expectVersion(current, version int) error {
+	if version != AnyVersion && current != version {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+// lockVersion locks the live row matched by q for the rest of its
+// transaction and checks it with expectVersion. A missing row is left for the
+// write to report.
+func lockVersion(q *orm.Query, version int) error {
+	if version == AnyVersion {
+		return nil
+	}
+
+	if hasTrash(q) {
+		q = q.Apply(alive)
+	}
+
+	var current int
+	err := q.ColumnExpr("?TableAlias.version").For("UPDATE").Select(pg.Scan(&current))
+	if err == pg.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return expectVersion(current, version)
+}
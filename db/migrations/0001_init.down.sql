@@ -0,0 +1,5 @@
+DROP TABLE core.food;
+DROP TABLE core.food_type;
+DROP TABLE core.brand;
+DROP TABLE core.category;
+DROP SCHEMA core;
@@ -0,0 +1,61 @@
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE SCHEMA IF NOT EXISTS "user";
+CREATE SCHEMA IF NOT EXISTS core;
+
+-- Owned by the auth service. Created here only so a fresh database is usable
+-- on its own, which is why the down migration leaves it in place.
+CREATE TABLE IF NOT EXISTS "user".app_user (
+	id        uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp timestamptz NOT NULL DEFAULT now(),
+	name      text NOT NULL UNIQUE
+);
+
+CREATE TABLE core.category (
+	id        uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp timestamptz NOT NULL DEFAULT now(),
+	"user"    uuid NOT NULL REFERENCES "user".app_user (id),
+	name      text NOT NULL UNIQUE
+);
+
+CREATE TABLE core.brand (
+	id        uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp timestamptz NOT NULL DEFAULT now(),
+	"user"    uuid NOT NULL REFERENCES "user".app_user (id),
+	name      text NOT NULL UNIQUE
+);
+
+CREATE TABLE core.food_type (
+	id        uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp timestamptz NOT NULL DEFAULT now(),
+	"user"    uuid NOT NULL REFERENCES "user".app_user (id),
+	category  uuid NOT NULL REFERENCES core.category (id),
+	name      text NOT NULL UNIQUE
+);
+
+CREATE TABLE core.food (
+	id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp   timestamptz NOT NULL DEFAULT now(),
+	"user"      uuid NOT NULL REFERENCES "user".app_user (id),
+	food_type   uuid NOT NULL REFERENCES core.food_type (id),
+	brand       uuid NOT NULL REFERENCES core.brand (id),
+	name        text NOT NULL,
+	kcal        real NOT NULL DEFAULT 0,
+	protein     real NOT NULL DEFAULT 0,
+	carbs       real NOT NULL DEFAULT 0,
+	fat         real NOT NULL DEFAULT 0,
+	saturated   real NOT NULL DEFAULT 0,
+	unsaturated real NOT NULL DEFAULT 0,
+	fiber       real NOT NULL DEFAULT 0,
+	sugars      real NOT NULL DEFAULT 0,
+	UNIQUE (brand, name)
+);
+
+CREATE INDEX food_type_category_idx ON core.food_type (category);
+CREATE INDEX food_food_type_idx ON core.food (food_type);
+
+-- Default list order and keyset paging on (timestamp, id).
+CREATE INDEX category_timestamp_id_idx ON core.category (timestamp DESC, id DESC);
+CREATE INDEX brand_timestamp_id_idx ON core.brand (timestamp DESC, id DESC);
+CREATE INDEX food_type_timestamp_id_idx ON core.food_type (timestamp DESC, id DESC);
+CREATE INDEX food_timestamp_id_idx ON core.food (timestamp DESC, id DESC);
@@ -0,0 +1,6 @@
+DROP INDEX core.food_name_tsv_idx;
+DROP INDEX core.food_type_name_trgm_idx;
+DROP INDEX core.brand_name_trgm_idx;
+DROP INDEX core.food_name_trgm_idx;
+
+DROP FUNCTION core.immutable_unaccent(text);
@@ -0,0 +1,14 @@
+-- Extensions and indexes backing GET /api/v1/foods/search.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- unaccent() is only STABLE, so it cannot be used in an index expression directly.
+CREATE OR REPLACE FUNCTION core.immutable_unaccent(text) RETURNS text
+	LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+	AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$;
+
+CREATE INDEX food_name_trgm_idx ON core.food USING gin (core.immutable_unaccent(name) gin_trgm_ops);
+CREATE INDEX brand_name_trgm_idx ON core.brand USING gin (core.immutable_unaccent(name) gin_trgm_ops);
+CREATE INDEX food_type_name_trgm_idx ON core.food_type USING gin (core.immutable_unaccent(name) gin_trgm_ops);
+
+CREATE INDEX food_name_tsv_idx ON core.food USING gin (to_tsvector('simple', core.immutable_unaccent(name)));
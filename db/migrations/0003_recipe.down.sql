@@ -0,0 +1,2 @@
+DROP TABLE core.recipe_ingredient;
+DROP TABLE core.recipe;
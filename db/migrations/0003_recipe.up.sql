@@ -0,0 +1,19 @@
+CREATE TABLE core.recipe (
+	id        uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp timestamptz NOT NULL DEFAULT now(),
+	"user"    uuid NOT NULL REFERENCES "user".app_user (id),
+	name      text NOT NULL,
+	servings  integer NOT NULL CHECK (servings > 0)
+);
+
+CREATE TABLE core.recipe_ingredient (
+	id     uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	recipe uuid NOT NULL REFERENCES core.recipe (id) ON DELETE CASCADE,
+	food   uuid NOT NULL REFERENCES core.food (id),
+	grams  real NOT NULL CHECK (grams > 0)
+);
+
+CREATE INDEX recipe_user_idx ON core.recipe ("user");
+CREATE INDEX recipe_timestamp_id_idx ON core.recipe (timestamp DESC, id DESC);
+CREATE INDEX recipe_ingredient_recipe_idx ON core.recipe_ingredient (recipe);
+CREATE INDEX recipe_ingredient_food_idx ON core.recipe_ingredient (food);
@@ -0,0 +1,13 @@
+CREATE TABLE core.diary_entry (
+	id        uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp timestamptz NOT NULL DEFAULT now(),
+	"user"    uuid NOT NULL REFERENCES "user".app_user (id),
+	food      uuid NOT NULL REFERENCES core.food (id),
+	grams     real NOT NULL CHECK (grams > 0),
+	meal      text NOT NULL CHECK (meal IN ('breakfast', 'lunch', 'dinner', 'snack')),
+	eaten_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX diary_entry_user_eaten_at_idx ON core.diary_entry ("user", eaten_at);
+CREATE INDEX diary_entry_timestamp_id_idx ON core.diary_entry (timestamp DESC, id DESC);
+CREATE INDEX diary_entry_food_idx ON core.diary_entry (food);
@@ -0,0 +1 @@
+DROP TABLE core.goal;
@@ -0,0 +1,17 @@
+-- Daily nutrition goals. Each row is a version that applies from
+-- effective_from until the next version; a NULL target means no goal.
+CREATE TABLE core.goal (
+	id             uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	timestamp      timestamptz NOT NULL DEFAULT now(),
+	"user"         uuid NOT NULL REFERENCES "user".app_user (id),
+	effective_from date NOT NULL,
+	kcal           real,
+	protein        real,
+	carbs          real,
+	fat            real,
+	fiber          real,
+	sugars         real,
+	UNIQUE ("user", effective_from)
+);
+
+CREATE INDEX goal_timestamp_id_idx ON core.goal (timestamp DESC, id DESC);
@@ -0,0 +1,10 @@
+-- Household servings such as "1 slice" or "1 cup". milliliters is optional and
+-- gives the food a density for volume conversions.
+CREATE TABLE core.food_serving (
+	id          uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	food        uuid NOT NULL REFERENCES core.food (id) ON DELETE CASCADE,
+	label       text NOT NULL,
+	grams       real NOT NULL CHECK (grams > 0),
+	milliliters real CHECK (milliliters > 0),
+	UNIQUE (food, label)
+);
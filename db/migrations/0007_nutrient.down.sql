@@ -0,0 +1,2 @@
+DROP TABLE core.food_nutrient;
+DROP TABLE core.nutrient;
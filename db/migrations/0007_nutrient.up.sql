@@ -0,0 +1,38 @@
+-- Catalogue of nutrients beyond the legacy macro columns on core.food.
+CREATE TABLE core.nutrient (
+	code      text PRIMARY KEY CHECK (code ~ '^[a-z][a-z0-9_]*$'),
+	timestamp timestamptz NOT NULL DEFAULT now(),
+	name      text NOT NULL,
+	unit      text NOT NULL,
+	category  text NOT NULL
+);
+
+-- Amounts are per 100 g, like the legacy columns.
+CREATE TABLE core.food_nutrient (
+	food     uuid NOT NULL REFERENCES core.food (id) ON DELETE CASCADE,
+	nutrient text NOT NULL REFERENCES core.nutrient (code) ON UPDATE CASCADE,
+	amount   real NOT NULL CHECK (amount >= 0),
+	PRIMARY KEY (food, nutrient)
+);
+
+CREATE INDEX food_nutrient_nutrient_idx ON core.food_nutrient (nutrient);
+
+INSERT INTO core.nutrient (code, name, unit, category) VALUES
+	('salt', 'Salt', 'g', 'mineral'),
+	('sodium', 'Sodium', 'mg', 'mineral'),
+	('potassium', 'Potassium', 'mg', 'mineral'),
+	('calcium', 'Calcium', 'mg', 'mineral'),
+	('magnesium', 'Magnesium', 'mg', 'mineral'),
+	('iron', 'Iron', 'mg', 'mineral'),
+	('zinc', 'Zinc', 'mg', 'mineral'),
+	('vitamin_a', 'Vitamin A', 'µg', 'vitamin'),
+	('vitamin_b12', 'Vitamin B12', 'µg', 'vitamin'),
+	('vitamin_c', 'Vitamin C', 'mg', 'vitamin'),
+	('vitamin_d', 'Vitamin D', 'µg', 'vitamin'),
+	('vitamin_e', 'Vitamin E', 'mg', 'vitamin'),
+	('vitamin_k', 'Vitamin K', 'µg', 'vitamin'),
+	('folate', 'Folate', 'µg', 'vitamin'),
+	('cholesterol', 'Cholesterol', 'mg', 'lipid'),
+	('trans_fat', 'Trans fat', 'g', 'lipid'),
+	('monounsaturated', 'Monounsaturated fat', 'g', 'lipid'),
+	('polyunsaturated', 'Polyunsaturated fat', 'g', 'lipid');
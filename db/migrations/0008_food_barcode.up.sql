@@ -0,0 +1,8 @@
+-- Codes are stored as zero padded GTIN-14, see package gtin.
+CREATE TABLE core.food_barcode (
+	code      text PRIMARY KEY CHECK (code ~ '^[0-9]{14}$'),
+	timestamp timestamptz NOT NULL DEFAULT now(),
+	food      uuid NOT NULL REFERENCES core.food (id) ON DELETE CASCADE
+);
+
+CREATE INDEX food_barcode_food_idx ON core.food_barcode (food);
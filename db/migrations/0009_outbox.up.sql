@@ -0,0 +1,16 @@
+-- Events written in the same transaction as the change they describe and
+-- published to JetStream afterwards by the relay, see data/outbox.go.
+CREATE TABLE core.outbox (
+	id           uuid PRIMARY KEY,
+	timestamp    timestamptz NOT NULL DEFAULT now(),
+	type         text NOT NULL,
+	payload      jsonb NOT NULL,
+	status       text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'published', 'dead')),
+	attempts     integer NOT NULL DEFAULT 0,
+	next_attempt timestamptz NOT NULL DEFAULT now(),
+	last_error   text,
+	published_at timestamptz
+);
+
+CREATE INDEX outbox_pending_idx ON core.outbox (next_attempt, timestamp) WHERE status = 'pending';
+CREATE INDEX outbox_status_idx ON core.outbox (status, timestamp);
@@ -0,0 +1 @@
+DROP TABLE core.user_event;
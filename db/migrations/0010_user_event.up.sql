@@ -0,0 +1,15 @@
+-- Owner of the catalogue rows of deleted users, so they stay usable without
+-- pointing at anyone. Like "user".app_user itself it is left in place by the
+-- down migration.
+INSERT INTO "user".app_user (id, name)
+VALUES ('6d1f5c3e-0a47-4b8e-9c2d-7e51f0a3b9d4', 'Deleted user')
+ON CONFLICT DO NOTHING;
+
+-- User lifecycle events already applied, see data/user.go, so a redelivered
+-- event is acknowledged without being applied twice.
+CREATE TABLE core.user_event (
+	key          text PRIMARY KEY,
+	type         text NOT NULL,
+	"user"       uuid NOT NULL,
+	processed_at timestamptz NOT NULL DEFAULT now()
+);
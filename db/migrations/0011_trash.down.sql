@@ -0,0 +1,50 @@
+DROP INDEX core.category_deleted_at_idx;
+DROP INDEX core.brand_deleted_at_idx;
+DROP INDEX core.food_type_deleted_at_idx;
+DROP INDEX core.food_deleted_at_idx;
+DROP INDEX core.recipe_deleted_at_idx;
+DROP INDEX core.diary_entry_deleted_at_idx;
+DROP INDEX core.goal_deleted_at_idx;
+
+-- Rows in the trash come back as live rows. Those that share a name with
+-- another row, which the partial indexes allow, are renamed after their id so
+-- the plain constraints can be restored; a goal has nothing to rename and the
+-- one in the trash is dropped instead.
+UPDATE core.category t SET name = t.name || ' (deleted ' || t.id || ')'
+WHERE t.deleted_at IS NOT NULL
+AND EXISTS (SELECT 1 FROM core.category o WHERE o.name = t.name AND o.id <> t.id);
+UPDATE core.brand t SET name = t.name || ' (deleted ' || t.id || ')'
+WHERE t.deleted_at IS NOT NULL
+AND EXISTS (SELECT 1 FROM core.brand o WHERE o.name = t.name AND o.id <> t.id);
+UPDATE core.food_type t SET name = t.name || ' (deleted ' || t.id || ')'
+WHERE t.deleted_at IS NOT NULL
+AND EXISTS (SELECT 1 FROM core.food_type o WHERE o.name = t.name AND o.id <> t.id);
+UPDATE core.food t SET name = t.name || ' (deleted ' || t.id || ')'
+WHERE t.deleted_at IS NOT NULL
+AND EXISTS (SELECT 1 FROM core.food o WHERE o.brand = t.brand AND o.name = t.name AND o.id <> t.id);
+DELETE FROM core.goal t
+WHERE t.deleted_at IS NOT NULL
+AND EXISTS (
+	SELECT 1 FROM core.goal o
+	WHERE o."user" = t."user" AND o.effective_from = t.effective_from
+	AND (o.deleted_at IS NULL OR o.id < t.id)
+);
+
+DROP INDEX core.category_name_key;
+ALTER TABLE core.category ADD CONSTRAINT category_name_key UNIQUE (name);
+DROP INDEX core.brand_name_key;
+ALTER TABLE core.brand ADD CONSTRAINT brand_name_key UNIQUE (name);
+DROP INDEX core.food_type_name_key;
+ALTER TABLE core.food_type ADD CONSTRAINT food_type_name_key UNIQUE (name);
+DROP INDEX core.food_brand_name_key;
+ALTER TABLE core.food ADD CONSTRAINT food_brand_name_key UNIQUE (brand, name);
+DROP INDEX core.goal_user_effective_from_key;
+ALTER TABLE core.goal ADD CONSTRAINT goal_user_effective_from_key UNIQUE ("user", effective_from);
+
+ALTER TABLE core.category DROP COLUMN deleted_at, DROP COLUMN deleted_by;
+ALTER TABLE core.brand DROP COLUMN deleted_at, DROP COLUMN deleted_by;
+ALTER TABLE core.food_type DROP COLUMN deleted_at, DROP COLUMN deleted_by;
+ALTER TABLE core.food DROP COLUMN deleted_at, DROP COLUMN deleted_by;
+ALTER TABLE core.recipe DROP COLUMN deleted_at, DROP COLUMN deleted_by;
+ALTER TABLE core.diary_entry DROP COLUMN deleted_at, DROP COLUMN deleted_by;
+ALTER TABLE core.goal DROP COLUMN deleted_at, DROP COLUMN deleted_by;
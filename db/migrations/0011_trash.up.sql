@@ -0,0 +1,31 @@
+-- Rows are moved to the trash by setting deleted_at and only removed for good
+-- by the purge job once the retention period is over, see data/trash.go.
+
+ALTER TABLE core.category ADD COLUMN deleted_at timestamptz, ADD COLUMN deleted_by uuid;
+ALTER TABLE core.brand ADD COLUMN deleted_at timestamptz, ADD COLUMN deleted_by uuid;
+ALTER TABLE core.food_type ADD COLUMN deleted_at timestamptz, ADD COLUMN deleted_by uuid;
+ALTER TABLE core.food ADD COLUMN deleted_at timestamptz, ADD COLUMN deleted_by uuid;
+ALTER TABLE core.recipe ADD COLUMN deleted_at timestamptz, ADD COLUMN deleted_by uuid;
+ALTER TABLE core.diary_entry ADD COLUMN deleted_at timestamptz, ADD COLUMN deleted_by uuid;
+ALTER TABLE core.goal ADD COLUMN deleted_at timestamptz, ADD COLUMN deleted_by uuid;
+
+-- Uniqueness only applies among live rows, so a row in the trash does not
+-- block its replacement. Restoring it fails while the replacement is live.
+ALTER TABLE core.category DROP CONSTRAINT category_name_key;
+CREATE UNIQUE INDEX category_name_key ON core.category (name) WHERE deleted_at IS NULL;
+ALTER TABLE core.brand DROP CONSTRAINT brand_name_key;
+CREATE UNIQUE INDEX brand_name_key ON core.brand (name) WHERE deleted_at IS NULL;
+ALTER TABLE core.food_type DROP CONSTRAINT food_type_name_key;
+CREATE UNIQUE INDEX food_type_name_key ON core.food_type (name) WHERE deleted_at IS NULL;
+ALTER TABLE core.food DROP CONSTRAINT food_brand_name_key;
+CREATE UNIQUE INDEX food_brand_name_key ON core.food (brand, name) WHERE deleted_at IS NULL;
+ALTER TABLE core.goal DROP CONSTRAINT goal_user_effective_from_key;
+CREATE UNIQUE INDEX goal_user_effective_from_key ON core.goal ("user", effective_from) WHERE deleted_at IS NULL;
+
+CREATE INDEX category_deleted_at_idx ON core.category (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX brand_deleted_at_idx ON core.brand (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX food_type_deleted_at_idx ON core.food_type (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX food_deleted_at_idx ON core.food (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX recipe_deleted_at_idx ON core.recipe (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX diary_entry_deleted_at_idx ON core.diary_entry (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX goal_deleted_at_idx ON core.goal (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE core.audit_log;
+DROP FUNCTION core.audit_log_append_only();
@@ -0,0 +1,28 @@
+-- Every change to a catalogue entity, written in the same transaction as the
+-- change, see data/audit.go. Rows share their id with the event published for
+-- the change. The log is append-only: rows are never updated or deleted, not
+-- even when the entity is purged from the trash.
+CREATE TABLE core.audit_log (
+	id         uuid PRIMARY KEY,
+	timestamp  timestamptz NOT NULL DEFAULT now(),
+	entity     text NOT NULL,
+	entity_id  text NOT NULL,
+	action     text NOT NULL,
+	actor      uuid NOT NULL,
+	request_id text,
+	before     jsonb,
+	after      jsonb
+);
+
+CREATE INDEX audit_log_entity_idx ON core.audit_log (entity, entity_id, timestamp DESC, id DESC);
+
+CREATE FUNCTION core.audit_log_append_only() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	RAISE EXCEPTION 'core.audit_log is append-only';
+END;
+$$;
+
+CREATE TRIGGER audit_log_append_only
+BEFORE UPDATE OR DELETE ON core.audit_log
+FOR EACH ROW EXECUTE FUNCTION core.audit_log_append_only();
@@ -0,0 +1,25 @@
+DROP TRIGGER goal_version ON core.goal;
+ALTER TABLE core.goal DROP COLUMN version;
+
+DROP TRIGGER diary_entry_version ON core.diary_entry;
+ALTER TABLE core.diary_entry DROP COLUMN version;
+
+DROP TRIGGER recipe_version ON core.recipe;
+ALTER TABLE core.recipe DROP COLUMN version;
+
+DROP TRIGGER nutrient_version ON core.nutrient;
+ALTER TABLE core.nutrient DROP COLUMN version;
+
+DROP TRIGGER food_version ON core.food;
+ALTER TABLE core.food DROP COLUMN version;
+
+DROP TRIGGER food_type_version ON core.food_type;
+ALTER TABLE core.food_type DROP COLUMN version;
+
+DROP TRIGGER brand_version ON core.brand;
+ALTER TABLE core.brand DROP COLUMN version;
+
+DROP TRIGGER category_version ON core.category;
+ALTER TABLE core.category DROP COLUMN version;
+
+DROP FUNCTION core.bump_version();
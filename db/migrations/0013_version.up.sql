@@ -0,0 +1,36 @@
+-- Optimistic concurrency: every update of an entity bumps its version, which
+-- is served as its ETag. Writes sent with If-Match only go ahead at the
+-- version the client read, see data/version.go. Rows that are never
+-- updated, such as barcodes and recipe ingredients, are replaced by an update
+-- of the entity they belong to, which bumps its version.
+CREATE FUNCTION core.bump_version() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+	NEW.version := OLD.version + 1;
+	RETURN NEW;
+END;
+$$;
+
+ALTER TABLE core.category ADD COLUMN version integer NOT NULL DEFAULT 1;
+CREATE TRIGGER category_version BEFORE UPDATE ON core.category FOR EACH ROW EXECUTE FUNCTION core.bump_version();
+
+ALTER TABLE core.brand ADD COLUMN version integer NOT NULL DEFAULT 1;
+CREATE TRIGGER brand_version BEFORE UPDATE ON core.brand FOR EACH ROW EXECUTE FUNCTION core.bump_version();
+
+ALTER TABLE core.food_type ADD COLUMN version integer NOT NULL DEFAULT 1;
+CREATE TRIGGER food_type_version BEFORE UPDATE ON core.food_type FOR EACH ROW EXECUTE FUNCTION core.bump_version();
+
+ALTER TABLE core.food ADD COLUMN version integer NOT NULL DEFAULT 1;
+CREATE TRIGGER food_version BEFORE UPDATE ON core.food FOR EACH ROW EXECUTE FUNCTION core.bump_version();
+
+ALTER TABLE core.nutrient ADD COLUMN version integer NOT NULL DEFAULT 1;
+CREATE TRIGGER nutrient_version BEFORE UPDATE ON core.nutrient FOR EACH ROW EXECUTE FUNCTION core.bump_version();
+
+ALTER TABLE core.recipe ADD COLUMN version integer NOT NULL DEFAULT 1;
+CREATE TRIGGER recipe_version BEFORE UPDATE ON core.recipe FOR EACH ROW EXECUTE FUNCTION core.bump_version();
+
+ALTER TABLE core.diary_entry ADD COLUMN version integer NOT NULL DEFAULT 1;
+CREATE TRIGGER diary_entry_version BEFORE UPDATE ON core.diary_entry FOR EACH ROW EXECUTE FUNCTION core.bump_version();
+
+ALTER TABLE core.goal ADD COLUMN version integer NOT NULL DEFAULT 1;
+CREATE TRIGGER goal_version BEFORE UPDATE ON core.goal FOR EACH ROW EXECUTE FUNCTION core.bump_version();
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+)
+
+// Version is bumped whenever the payload of an existing event type changes
+// in a way consumers have to know about.
+const Version = 1
+
+const (
+	Created = "created"
+	Updated = "updated"
+	Deleted = "deleted"
+	// Restored is sent when a deleted entity is taken out of the trash. Its
+	// After is the entity as it is again.
+	Restored = "restored"
+)
+
+// Event describes one change to a catalogue entity. Type is
+// "<entity>.<action>", e.g. "food.updated". Before is empty for created
+// events and After for deleted ones.
+type Event struct {
+	Id        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	Version   int             `json:"version"`
+	Entity    string          `json:"entity"`
+	EntityId  string          `json:"entityId"`
+	Actor     uuid.UUID       `json:"actor"`
+	Timestamp time.Time       `json:"timestamp"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+}
+
+// New builds the event for action on the entity with id, marshalling before
+// and after unless they are nil.
+func New(entity, action, id string, actor uuid.UUID, before, after any) (Event, error) {
+	event := Event{
+		Id:        uuid.New(),
+		Type:      fmt.Sprintf("%v.%v", entity, action),
+		Version:   Version,
+		Entity:    entity,
+		EntityId:  id,
+		Actor:     actor,
+		Timestamp: time.Now().UTC(),
+	}
+
+	var err error
+	if before != nil {
+		event.Before, err = json.Marshal(before)
+		if err != nil {
+			return event, fmt.Errorf("failed to marshal %v: %w", event.Type, err)
+		}
+	}
+
+	if after != nil {
+		event.After, err = json.Marshal(after)
+		if err != nil {
+			return event, fmt.Errorf("failed to marshal %v: %w", event.Type, err)
+		}
+	}
+
+	return event, nil
+}
+
+// Subject is the subject an event of eventType is published on below prefix,
+// e.g. "culinary.food.updated".
+func Subject(prefix, eventType string) string {
+	return fmt.Sprintf("%v.%v", prefix, eventType)
+}
+
+// EnsureStream creates the stream name capturing every subject below prefix
+// unless it already exists.
+func EnsureStream(js nats.JetStreamContext, name, prefix string) error {
+	_, err := js.StreamInfo(name)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, nats.ErrStreamNotFound) {
+		return err
+	}
+
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     name,
+		Subjects: []string{prefix + ".>"},
+		Storage:  nats.FileStorage,
+	})
+	return err
+}
+
+// Publish sends an encoded event of eventType below prefix and waits for the
+// stream to store it. The event id doubles as the Nats-Msg-Id, so JetStream
+// drops a copy sent again within its duplicate window.
+func Publish(js nats.JetStreamContext, prefix, eventType string, id uuid.UUID, payload []byte) error {
+	_, err := js.Publish(Subject(prefix, eventType), payload, nats.MsgId(id.String()))
+	return err
+}
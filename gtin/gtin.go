@@ -0,0 +1,62 @@
+// Package gtin validates and normalizes GTIN barcodes (EAN-8, UPC-A, EAN-13
+// and GTIN-14).
+package gtin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalid  = errors.New("invalid barcode")
+	ErrChecksum = errors.New("barcode check digit mismatch")
+)
+
+// Length is the length of a normalized code.
+const Length = 14
+
+// Normalize strips spaces and dashes from code, verifies its check digit and
+// left pads it with zeros to 14 digits, so the UPC-A, EAN-13 and GTIN-14
+// spellings of the same product compare equal.
+func Normalize(code string) (string, error) {
+	code = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return "", fmt.Errorf("%w: %q must have 8, 12, 13 or 14 digits", ErrInvalid, code)
+	}
+
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("%w: %q must only contain digits", ErrInvalid, code)
+		}
+	}
+
+	if CheckDigit(code[:len(code)-1]) != code[len(code)-1] {
+		return "", fmt.Errorf("%w: %q", ErrChecksum, code)
+	}
+
+	return strings.Repeat("0", Length-len(code)) + code, nil
+}
+
+// CheckDigit computes the GS1 mod 10 check digit for digits, which must not
+// include the check digit itself.
+func CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 0 {
+			d *= 3
+		}
+		sum += d
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,90 @@
+package gtin
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		want    string
+		wantErr error
+	}{
+		{name: "EAN-13", code: "4006381333931", want: "04006381333931"},
+		{name: "UPC-A", code: "036000291452", want: "00036000291452"},
+		{name: "EAN-8", code: "96385074", want: "00000096385074"},
+		{name: "GTIN-14", code: "10012345678902", want: "10012345678902"},
+		{name: "UPC-A as EAN-13", code: "0036000291452", want: "00036000291452"},
+		{name: "spaces and dashes", code: "400-6381 333931", want: "04006381333931"},
+		{name: "all zeros", code: "00000000", want: "00000000000000"},
+		{name: "empty", code: "", wantErr: ErrInvalid},
+		{name: "too short", code: "1234567", wantErr: ErrInvalid},
+		{name: "nine digits", code: "123456789", wantErr: ErrInvalid},
+		{name: "too long", code: "400638133393100", wantErr: ErrInvalid},
+		{name: "letters", code: "40063813339A1", wantErr: ErrInvalid},
+		{name: "unicode digits", code: "٤٠٠٦٣٨١٣", wantErr: ErrInvalid},
+		{name: "wrong check digit", code: "4006381333932", wantErr: ErrChecksum},
+		{name: "swapped digits", code: "4006381339331", wantErr: ErrChecksum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.code)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Normalize(%q): got error %v, want %v", tt.code, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Normalize(%q): %v", tt.code, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+
+			if len(got) != Length {
+				t.Errorf("Normalize(%q) has %v digits, want %v", tt.code, len(got), Length)
+			}
+		})
+	}
+}
+
+func TestCheckDigit(t *testing.T) {
+	tests := []struct {
+		digits string
+		want   byte
+	}{
+		{"400638133393", '1'},
+		{"03600029145", '2'},
+		{"9638507", '4'},
+		{"1001234567890", '2'},
+		{"0000000", '0'},
+		{"", '0'},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.digits, func(t *testing.T) {
+			got := CheckDigit(tt.digits)
+			if got != tt.want {
+				t.Errorf("CheckDigit(%q) = %q, want %q", tt.digits, got, tt.want)
+			}
+		})
+	}
+}
+
+// Left padding must not change the check digit, or the spellings of one
+// product would not normalize to the same code.
+func TestCheckDigitIgnoresLeadingZeros(t *testing.T) {
+	for _, digits := range []string{"9638507", "03600029145", "400638133393"} {
+		padded := "0000000" + digits
+		if CheckDigit(digits) != CheckDigit(padded) {
+			t.Errorf("CheckDigit(%q) = %q, CheckDigit(%q) = %q", digits, CheckDigit(digits), padded, CheckDigit(padded))
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/adamelfsborg-code/food/culinary/data"
 	"github.com/adamelfsborg-code/food/culinary/lib"
@@ -15,6 +17,15 @@ type BrandHandler struct {
 	Data data.DataConn
 }
 
+func parseBrandFilter(query url.Values) (*data.BrandFilterDto, error) {
+	filterId, err := lib.ParseQueryUUID(query, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	return data.NewBrandFilterDto(filterId, query.Get("name"))
+}
+
 func (u *BrandHandler) GetBrandById(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
@@ -32,6 +43,10 @@ func (u *BrandHandler) GetBrandById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if lib.NotModified(w, r, lib.ETag(brands.Version)) {
+		return
+	}
+
 	jsonBytes, err := json.Marshal(brands)
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
@@ -45,30 +60,38 @@ func (u *BrandHandler) GetBrandById(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *BrandHandler) ListBrands(w http.ResponseWriter, r *http.Request) {
-	pageIndex := r.URL.Query().Get("pageIndex")
-	pageSize := r.URL.Query().Get("pageSize")
-
-	pagination, err := lib.NewPagination(pageIndex, pageSize)
+	pagination, err := lib.ParsePagination(r.URL.Query())
 	if err != nil {
 		fmt.Println("Failed to parse pagination: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	brands, err := u.Data.ListBrands(pagination.PageIndex, pagination.PageSize)
+	filter, err := parseBrandFilter(r.URL.Query())
 	if err != nil {
-		fmt.Println("Failed to get brand: ", err)
+		fmt.Println("Failed to parse filter: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	count, err := u.Data.CountBrands()
+	brands, cursors, err := u.Data.ListBrands(*filter, pagination.Page())
 	if err != nil {
 		fmt.Println("Failed to get brand: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountBrands(*filter)
+		if err != nil {
+			fmt.Println("Failed to get brand: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
 	response := lib.NewPaginatedResponse(brands, count, *pagination)
 
 	jsonBytes, err := json.Marshal(response)
@@ -84,9 +107,7 @@ func (u *BrandHandler) ListBrands(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *BrandHandler) CreateBrand(w http.ResponseWriter, r *http.Request) {
-	headerId := r.Header.Get("X-USER-ID")
-
-	userId, err := uuid.Parse(headerId)
+	actor, err := lib.ActorFromRequest(r)
 	if err != nil {
 		fmt.Println("Failed to parse id: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -104,14 +125,14 @@ func (u *BrandHandler) CreateBrand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	brand, err := data.NewBrandDto(userId, body.Name)
+	brand, err := data.NewBrandDto(actor.Id, body.Name)
 	if err != nil {
 		fmt.Println("Failed to extract brand details: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = u.Data.CreateBrand(*brand)
+	err = u.Data.CreateBrand(actor, *brand)
 	if err != nil {
 		fmt.Println("Failed to create brand: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -131,6 +152,13 @@ func (u *BrandHandler) CreateBrand(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *BrandHandler) EditBrand(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := chi.URLParam(r, "id")
 
 	brand, err := uuid.Parse(id)
@@ -151,10 +179,17 @@ func (u *BrandHandler) EditBrand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = u.Data.EditBrand(brand, body.Name)
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.EditBrand(actor, brand, version, body.Name)
 	if err != nil {
 		fmt.Println("Failed to delete brand: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -171,6 +206,13 @@ func (u *BrandHandler) EditBrand(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *BrandHandler) DeleteBrand(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := chi.URLParam(r, "id")
 
 	brand, err := uuid.Parse(id)
@@ -180,10 +222,17 @@ func (u *BrandHandler) DeleteBrand(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = u.Data.DeleteBrand(brand)
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.DeleteBrand(actor, brand, version)
 	if err != nil {
 		fmt.Println("Failed to delete brand: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -198,3 +247,193 @@ func (u *BrandHandler) DeleteBrand(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 }
+
+var brandColumns = []string{"id", "timestamp", "user", "name"}
+
+func brandRecord(brand data.BrandDto) []string {
+	return []string{brand.Id.String(), brand.Timestamp.Format(time.RFC3339Nano), brand.User.String(), brand.Name}
+}
+
+func (u *BrandHandler) ExportBrands(w http.ResponseWriter, r *http.Request) {
+	format, err := lib.ParseFormat(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse format: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseBrandFilter(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse filter: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	export, err := lib.NewExportWriter(w, format, "brands", brandColumns)
+	if err != nil {
+		fmt.Println("Failed to start export: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.ExportBrands(*filter, func(brand data.BrandDto) error {
+		return export.Write(brand, brandRecord(brand))
+	})
+	if err == nil {
+		err = export.Flush()
+	}
+
+	// The status line is gone by now, so a failure can only cut the
+	// download short.
+	if err != nil {
+		fmt.Println("Failed to export brands: ", err)
+	}
+}
+
+func (u *BrandHandler) ImportBrands(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := lib.ParseFormat(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse format: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun, err := lib.ParseQueryBool(r.URL.Query(), "dryRun")
+	if err != nil {
+		fmt.Println("Failed to parse dry run: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rows []data.ImportRow[data.BrandDto]
+	count, rowErrors, err := lib.ReadRecords(r.Body, format, func(row int, record lib.Record) error {
+		id, err := lib.ParseRecordUUID(record, "id")
+		if err != nil {
+			return err
+		}
+
+		brand, err := data.NewBrandDto(actor.Id, record("name"))
+		if err != nil {
+			return err
+		}
+
+		brand.Id = id
+		rows = append(rows, data.ImportRow[data.BrandDto]{Row: row, Value: *brand})
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Failed to read import: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := u.Data.ImportBrands(actor, rows, data.ImportReport{Rows: count, DryRun: dryRun, Errors: rowErrors})
+	if err != nil {
+		fmt.Println("Failed to import brands: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeImportReport(w, report)
+}
+
+// ListBrandTrash lists the brand rows in the trash; sort=-deletedAt puts
+// the most recently deleted first.
+func (u *BrandHandler) ListBrandTrash(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, cursors, err := u.Data.ListBrandTrash(actor, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get brand trash: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountBrandTrash(actor)
+		if err != nil {
+			fmt.Println("Failed to count brand trash: ", err)
+			http.Error(w, err.Error(), lib.ErrorStatus(err))
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(rows, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *BrandHandler) RestoreBrand(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	rowId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.RestoreBrand(actor, rowId)
+	if err != nil {
+		fmt.Println("Failed to restore brand: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Brand Restored"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// ListBrandHistory lists the changes to a brand, newest first.
+func (u *BrandHandler) ListBrandHistory(w http.ResponseWriter, r *http.Request) {
+	listHistory(w, r, u.Data, "brand")
+}
+
+func (u *BrandHandler) RevertBrand(w http.ResponseWriter, r *http.Request) {
+	revert(w, r, u.Data, "Brand Reverted", u.Data.RevertBrand)
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+)
+
+// writeImportReport answers a bulk import. A report with row errors is a 422
+// so clients cannot mistake a rolled back import for a successful one.
+func writeImportReport(w http.ResponseWriter, report data.ImportReport) {
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	if len(report.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonBytes)
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/adamelfsborg-code/food/culinary/data"
 	"github.com/adamelfsborg-code/food/culinary/lib"
@@ -15,6 +17,15 @@ type CategoryHandler struct {
 	Data data.DataConn
 }
 
+func parseCategoryFilter(query url.Values) (*data.CategoryFilterDto, error) {
+	filterId, err := lib.ParseQueryUUID(query, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	return data.NewCategoryFilterDto(filterId, query.Get("name"))
+}
+
 func (u *CategoryHandler) GetCategoryById(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
@@ -32,6 +43,10 @@ func (u *CategoryHandler) GetCategoryById(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if lib.NotModified(w, r, lib.ETag(catgories.Version)) {
+		return
+	}
+
 	jsonBytes, err := json.Marshal(catgories)
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
@@ -45,30 +60,38 @@ func (u *CategoryHandler) GetCategoryById(w http.ResponseWriter, r *http.Request
 }
 
 func (u *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request) {
-	pageIndex := r.URL.Query().Get("pageIndex")
-	pageSize := r.URL.Query().Get("pageSize")
-
-	pagination, err := lib.NewPagination(pageIndex, pageSize)
+	pagination, err := lib.ParsePagination(r.URL.Query())
 	if err != nil {
 		fmt.Println("Failed to parse pagination: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	catgories, err := u.Data.ListCategories(pagination.PageIndex, pagination.PageSize)
+	filter, err := parseCategoryFilter(r.URL.Query())
 	if err != nil {
-		fmt.Println("Failed to get category: ", err)
+		fmt.Println("Failed to parse filter: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	count, err := u.Data.CountCategories()
+	catgories, cursors, err := u.Data.ListCategories(*filter, pagination.Page())
 	if err != nil {
-		fmt.Println("Failed to get brand: ", err)
+		fmt.Println("Failed to get category: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountCategories(*filter)
+		if err != nil {
+			fmt.Println("Failed to get brand: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
 	response := lib.NewPaginatedResponse(catgories, count, *pagination)
 
 	jsonBytes, err := json.Marshal(response)
@@ -85,9 +108,7 @@ func (u *CategoryHandler) ListCategories(w http.ResponseWriter, r *http.Request)
 }
 
 func (u *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
-	headerId := r.Header.Get("X-USER-ID")
-
-	userId, err := uuid.Parse(headerId)
+	actor, err := lib.ActorFromRequest(r)
 	if err != nil {
 		fmt.Println("Failed to parse id: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -105,14 +126,14 @@ func (u *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	category, err := data.NewCategoryDto(userId, body.Name)
+	category, err := data.NewCategoryDto(actor.Id, body.Name)
 	if err != nil {
 		fmt.Println("Failed to extract category details: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = u.Data.CreateCategory(*category)
+	err = u.Data.CreateCategory(actor, *category)
 	if err != nil {
 		fmt.Println("Failed to create category: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -132,6 +153,13 @@ func (u *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request)
 }
 
 func (u *CategoryHandler) EditCategory(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := chi.URLParam(r, "id")
 
 	category, err := uuid.Parse(id)
@@ -152,10 +180,17 @@ func (u *CategoryHandler) EditCategory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = u.Data.EditCategory(category, body.Name)
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.EditCategory(actor, category, version, body.Name)
 	if err != nil {
 		fmt.Println("Failed to delete category: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -172,6 +207,13 @@ func (u *CategoryHandler) EditCategory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := chi.URLParam(r, "id")
 
 	category, err := uuid.Parse(id)
@@ -181,10 +223,17 @@ func (u *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = u.Data.DeleteCategory(category)
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.DeleteCategory(actor, category, version)
 	if err != nil {
 		fmt.Println("Failed to delete category: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -199,3 +248,193 @@ func (u *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 }
+
+var categoryColumns = []string{"id", "timestamp", "user", "name"}
+
+func categoryRecord(category data.CategoryDto) []string {
+	return []string{category.Id.String(), category.Timestamp.Format(time.RFC3339Nano), category.User.String(), category.Name}
+}
+
+func (u *CategoryHandler) ExportCategories(w http.ResponseWriter, r *http.Request) {
+	format, err := lib.ParseFormat(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse format: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseCategoryFilter(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse filter: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	export, err := lib.NewExportWriter(w, format, "categories", categoryColumns)
+	if err != nil {
+		fmt.Println("Failed to start export: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.ExportCategories(*filter, func(category data.CategoryDto) error {
+		return export.Write(category, categoryRecord(category))
+	})
+	if err == nil {
+		err = export.Flush()
+	}
+
+	// The status line is gone by now, so a failure can only cut the
+	// download short.
+	if err != nil {
+		fmt.Println("Failed to export categories: ", err)
+	}
+}
+
+func (u *CategoryHandler) ImportCategories(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := lib.ParseFormat(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse format: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun, err := lib.ParseQueryBool(r.URL.Query(), "dryRun")
+	if err != nil {
+		fmt.Println("Failed to parse dry run: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rows []data.ImportRow[data.CategoryDto]
+	count, rowErrors, err := lib.ReadRecords(r.Body, format, func(row int, record lib.Record) error {
+		id, err := lib.ParseRecordUUID(record, "id")
+		if err != nil {
+			return err
+		}
+
+		category, err := data.NewCategoryDto(actor.Id, record("name"))
+		if err != nil {
+			return err
+		}
+
+		category.Id = id
+		rows = append(rows, data.ImportRow[data.CategoryDto]{Row: row, Value: *category})
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Failed to read import: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := u.Data.ImportCategories(actor, rows, data.ImportReport{Rows: count, DryRun: dryRun, Errors: rowErrors})
+	if err != nil {
+		fmt.Println("Failed to import categories: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	writeImportReport(w, report)
+}
+
+// ListCategoryTrash lists the category rows in the trash; sort=-deletedAt puts
+// the most recently deleted first.
+func (u *CategoryHandler) ListCategoryTrash(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, cursors, err := u.Data.ListCategoryTrash(actor, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get category trash: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountCategoryTrash(actor)
+		if err != nil {
+			fmt.Println("Failed to count category trash: ", err)
+			http.Error(w, err.Error(), lib.ErrorStatus(err))
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(rows, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *CategoryHandler) RestoreCategory(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	rowId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.RestoreCategory(actor, rowId)
+	if err != nil {
+		fmt.Println("Failed to restore category: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Category Restored"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// ListCategoryHistory lists the changes to a category, newest first.
+func (u *CategoryHandler) ListCategoryHistory(w http.ResponseWriter, r *http.Request) {
+	listHistory(w, r, u.Data, "category")
+}
+
+func (u *CategoryHandler) RevertCategory(w http.ResponseWriter, r *http.Request) {
+	revert(w, r, u.Data, "Category Reverted", u.Data.RevertCategory)
+}
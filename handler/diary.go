@@ -0,0 +1,439 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/lib"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type DiaryHandler struct {
+	Data data.DataConn
+}
+
+type diaryEntryBody struct {
+	Food    string     `json:"food"`
+	Grams   float32    `json:"grams"`
+	Meal    string     `json:"meal"`
+	EatenAt *time.Time `json:"eatenAt"`
+}
+
+func (b diaryEntryBody) entry(user uuid.UUID) (*data.DiaryEntryDto, error) {
+	food, err := uuid.Parse(b.Food)
+	if err != nil {
+		return nil, fmt.Errorf("invalid food: %w", err)
+	}
+
+	eatenAt := time.Now()
+	if b.EatenAt != nil {
+		eatenAt = *b.EatenAt
+	}
+
+	return data.NewDiaryEntry(user, food, b.Grams, b.Meal, eatenAt)
+}
+
+func (u *DiaryHandler) GetDiaryEntryById(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	entryId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry, err := u.Data.GetDiaryEntryById(userId, entryId)
+	if err != nil {
+		fmt.Println("Failed to get diary entry: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The nutrition depends on the food, so its changes change the tag too.
+	var foods []int
+	if entry.Food != nil {
+		foods = append(foods, entry.Food.Version)
+	}
+
+	if lib.NotModified(w, r, lib.ETag(entry.Version, foods...)) {
+		return
+	}
+
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *DiaryHandler) ListDiaryEntries(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := lib.ParseQueryTime(r.URL.Query(), "from")
+	if err != nil {
+		fmt.Println("Failed to parse filter: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := lib.ParseQueryTime(r.URL.Query(), "to")
+	if err != nil {
+		fmt.Println("Failed to parse filter: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := data.NewDiaryFilterDto(userId, r.URL.Query().Get("meal"), from, to)
+	if err != nil {
+		fmt.Println("Failed to parse filter: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, cursors, err := u.Data.ListDiaryEntries(*filter, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get diary entries: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountDiaryEntries(*filter)
+		if err != nil {
+			fmt.Println("Failed to count diary entries: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(entries, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *DiaryHandler) GetDiaryDay(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	date, err := time.Parse(time.DateOnly, chi.URLParam(r, "date"))
+	if err != nil {
+		fmt.Println("Failed to parse date: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	loc, err := lib.ParseQueryLocation(r.URL.Query(), "tz")
+	if err != nil {
+		fmt.Println("Failed to parse time zone: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	day, err := u.Data.GetDiaryDay(userId, date, loc)
+	if err != nil {
+		fmt.Println("Failed to get diary day: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(day)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *DiaryHandler) CreateDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body diaryEntryBody
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry, err := body.entry(userId)
+	if err != nil {
+		fmt.Println("Failed to extract diary entry details: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.CreateDiaryEntry(*entry)
+	if err != nil {
+		fmt.Println("Failed to create diary entry: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Diary Entry Created"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonBytes)
+}
+
+func (u *DiaryHandler) EditDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	entryId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body diaryEntryBody
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entry, err := body.entry(userId)
+	if err != nil {
+		fmt.Println("Failed to extract diary entry details: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.EditDiaryEntry(userId, entryId, version, entry.Food, entry.Grams, entry.Meal, entry.EatenAt)
+	if err != nil {
+		fmt.Println("Failed to edit diary entry: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Diary Entry Edited"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *DiaryHandler) DeleteDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	entryId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.DeleteDiaryEntry(userId, entryId, version)
+	if err != nil {
+		fmt.Println("Failed to delete diary entry: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Diary Entry Deleted"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// ListDiaryTrash lists the diary entry rows in the trash; sort=-deletedAt puts
+// the most recently deleted first.
+func (u *DiaryHandler) ListDiaryTrash(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, cursors, err := u.Data.ListDiaryTrash(userId, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get diary entry trash: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountDiaryTrash(userId)
+		if err != nil {
+			fmt.Println("Failed to count diary entry trash: ", err)
+			http.Error(w, err.Error(), lib.ErrorStatus(err))
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(rows, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *DiaryHandler) RestoreDiaryEntry(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	rowId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.RestoreDiaryEntry(userId, rowId)
+	if err != nil {
+		fmt.Println("Failed to restore diary entry: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Diary Entry Restored"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
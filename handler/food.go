@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/adamelfsborg-code/food/culinary/data"
 	"github.com/adamelfsborg-code/food/culinary/lib"
@@ -15,6 +19,67 @@ type FoodHandler struct {
 	Data data.DataConn
 }
 
+// maxBulkFoods caps the items of one bulk request.
+const maxBulkFoods = 1000
+
+type foodBody struct {
+	Id          string             `json:"id"`
+	Name        string             `json:"name"`
+	FoodType    string             `json:"foodtype"`
+	Brand       string             `json:"brand"`
+	KCAL        float32            `json:"kcal"`
+	Protein     float32            `json:"protein"`
+	Carbs       float32            `json:"carbs"`
+	Fat         float32            `json:"fat"`
+	Saturated   float32            `json:"saturated"`
+	Unsaturated float32            `json:"unsaturated"`
+	Fiber       float32            `json:"fiber"`
+	Sugars      float32            `json:"sugars"`
+	Nutrients   map[string]float32 `json:"nutrients"`
+	Barcodes    []string           `json:"barcodes"`
+	// Version makes a bulk update conditional, as If-Match does for a single
+	// one.
+	Version int `json:"version"`
+}
+
+func (b foodBody) food(user uuid.UUID) (*data.FoodDto, error) {
+	foodtype, err := uuid.Parse(b.FoodType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid foodtype: %w", err)
+	}
+
+	brand, err := uuid.Parse(b.Brand)
+	if err != nil {
+		return nil, fmt.Errorf("invalid brand: %w", err)
+	}
+
+	return data.NewFood(b.Name, b.KCAL, b.Protein, b.Carbs, b.Fat, b.Saturated, b.Unsaturated, b.Fiber, b.Sugars, b.Nutrients, b.Barcodes, user, foodtype, brand)
+}
+
+func parseFoodFilter(query url.Values) (*data.FoodFilterDto, error) {
+	filterId, err := lib.ParseQueryUUID(query, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	filterBrand, err := lib.ParseQueryUUID(query, "brand")
+	if err != nil {
+		return nil, err
+	}
+
+	filterFoodType, err := lib.ParseQueryUUID(query, "foodtype")
+	if err != nil {
+		return nil, err
+	}
+
+	filterCategory, err := lib.ParseQueryUUID(query, "category")
+	if err != nil {
+		return nil, err
+	}
+
+	return data.NewFoodFilterDto(filterId, query.Get("name"), filterBrand, filterFoodType, filterCategory)
+}
+
 func (u *FoodHandler) GetFoodById(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
@@ -32,6 +97,10 @@ func (u *FoodHandler) GetFoodById(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if lib.NotModified(w, r, lib.ETag(foodTypes.Version)) {
+		return
+	}
+
 	jsonBytes, err := json.Marshal(foodTypes)
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
@@ -44,31 +113,61 @@ func (u *FoodHandler) GetFoodById(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonBytes)
 }
 
-func (u *FoodHandler) ListFoods(w http.ResponseWriter, r *http.Request) {
-	pageIndex := r.URL.Query().Get("pageIndex")
-	pageSize := r.URL.Query().Get("pageSize")
+func (u *FoodHandler) GetFoodByBarcode(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	food, err := u.Data.GetFoodByBarcode(code)
+	if err != nil {
+		fmt.Println("Failed to get food: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(food)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	pagination, err := lib.NewPagination(pageIndex, pageSize)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *FoodHandler) ListFoods(w http.ResponseWriter, r *http.Request) {
+	pagination, err := lib.ParsePagination(r.URL.Query())
 	if err != nil {
 		fmt.Println("Failed to parse pagination: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	foods, err := u.Data.ListFoods(pagination.PageIndex, pagination.PageSize)
+	filter, err := parseFoodFilter(r.URL.Query())
 	if err != nil {
-		fmt.Println("Failed to get food: ", err)
+		fmt.Println("Failed to parse filter: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	count, err := u.Data.CountFoods()
+	foods, cursors, err := u.Data.ListFoods(*filter, pagination.Page())
 	if err != nil {
-		fmt.Println("Failed to count food: ", err)
+		fmt.Println("Failed to get food: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountFoods(*filter)
+		if err != nil {
+			fmt.Println("Failed to count food: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
 	response := lib.NewPaginatedResponse(foods, count, *pagination)
 
 	jsonBytes, err := json.Marshal(response)
@@ -83,62 +182,71 @@ func (u *FoodHandler) ListFoods(w http.ResponseWriter, r *http.Request) {
 	w.Write(jsonBytes)
 }
 
-func (u *FoodHandler) CreateFood(w http.ResponseWriter, r *http.Request) {
-	headerId := r.Header.Get("X-USER-ID")
+func (u *FoodHandler) SearchFoods(w http.ResponseWriter, r *http.Request) {
+	term := strings.TrimSpace(r.URL.Query().Get("q"))
+	if term == "" {
+		http.Error(w, "missing search query", http.StatusBadRequest)
+		return
+	}
 
-	user, err := uuid.Parse(headerId)
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 100 {
+			fmt.Println("Failed to parse limit: ", err)
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	foods, err := u.Data.SearchFoods(term, limit)
 	if err != nil {
-		fmt.Println("Failed to parse id: ", err)
+		fmt.Println("Failed to search food: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var body struct {
-		Name        string  `json:"name"`
-		FoodType    string  `json:"foodtype"`
-		Brand       string  `json:"brand"`
-		KCAL        float32 `json:"kcal"`
-		Protein     float32 `json:"protein"`
-		Carbs       float32 `json:"carbs"`
-		Fat         float32 `json:"fat"`
-		Saturated   float32 `json:"saturated"`
-		Unsaturated float32 `json:"unsaturated"`
-		Fiber       float32 `json:"fiber"`
-		Sugars      float32 `json:"sugars"`
-	}
-
-	err = json.NewDecoder(r.Body).Decode(&body)
+	jsonBytes, err := json.Marshal(foods)
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	foodtype, err := uuid.Parse(body.FoodType)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *FoodHandler) CreateFood(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
 	if err != nil {
-		fmt.Println("Failed to parse foodtype: ", err)
+		fmt.Println("Failed to parse id: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	brand, err := uuid.Parse(body.Brand)
+	var body foodBody
+
+	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
-		fmt.Println("Failed to parse brand: ", err)
+		fmt.Println("Failed to decode json: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	food, err := data.NewFood(body.Name, body.KCAL, body.Protein, body.Carbs, body.Fat, body.Saturated, body.Unsaturated, body.Fiber, body.Sugars, user, foodtype, brand)
+	food, err := body.food(actor.Id)
 	if err != nil {
 		fmt.Println("Failed to extract food details: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = u.Data.CreateFood(*food)
+	err = u.Data.CreateFood(actor, *food)
 	if err != nil {
 		fmt.Println("Failed to create food: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -155,6 +263,13 @@ func (u *FoodHandler) CreateFood(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *FoodHandler) EditFood(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := chi.URLParam(r, "id")
 
 	food, err := uuid.Parse(id)
@@ -164,19 +279,7 @@ func (u *FoodHandler) EditFood(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var body struct {
-		Name        string  `json:"name"`
-		FoodType    string  `json:"foodtype"`
-		Brand       string  `json:"brand"`
-		KCAL        float32 `json:"kcal"`
-		Protein     float32 `json:"protein"`
-		Carbs       float32 `json:"carbs"`
-		Fat         float32 `json:"fat"`
-		Saturated   float32 `json:"saturated"`
-		Unsaturated float32 `json:"unsaturated"`
-		Fiber       float32 `json:"fiber"`
-		Sugars      float32 `json:"sugars"`
-	}
+	var body foodBody
 
 	err = json.NewDecoder(r.Body).Decode(&body)
 	if err != nil {
@@ -199,10 +302,17 @@ func (u *FoodHandler) EditFood(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = u.Data.EditFood(body.Name, body.KCAL, body.Protein, body.Carbs, body.Fat, body.Saturated, body.Unsaturated, body.Fiber, body.Sugars, brand, foodtype, food)
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.EditFood(actor, body.Name, body.KCAL, body.Protein, body.Carbs, body.Fat, body.Saturated, body.Unsaturated, body.Fiber, body.Sugars, body.Nutrients, body.Barcodes, brand, foodtype, food, version)
 	if err != nil {
 		fmt.Println("Failed to edit food: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -219,6 +329,13 @@ func (u *FoodHandler) EditFood(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *FoodHandler) DeleteFood(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := chi.URLParam(r, "id")
 
 	foodType, err := uuid.Parse(id)
@@ -228,10 +345,17 @@ func (u *FoodHandler) DeleteFood(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = u.Data.DeleteFood(foodType)
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.DeleteFood(actor, foodType, version)
 	if err != nil {
 		fmt.Println("Failed to delete foodType: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -246,3 +370,531 @@ func (u *FoodHandler) DeleteFood(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 }
+
+func (u *FoodHandler) GetFoodNutrition(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	food, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	quantity := float64(100)
+	if value := r.URL.Query().Get("quantity"); value != "" {
+		quantity, err = strconv.ParseFloat(value, 32)
+		if err != nil {
+			fmt.Println("Failed to parse quantity: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	unitName := r.URL.Query().Get("unit")
+	if unitName == "" {
+		unitName = "g"
+	}
+
+	nutrition, err := u.Data.CalculateFoodNutrition(food, float32(quantity), unitName)
+	if err != nil {
+		fmt.Println("Failed to calculate nutrition: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(nutrition)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *FoodHandler) ListFoodServings(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	food, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	servings, err := u.Data.ListFoodServings(food)
+	if err != nil {
+		fmt.Println("Failed to get servings: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(servings)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *FoodHandler) CreateFoodServing(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	food, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Label       string   `json:"label"`
+		Grams       float32  `json:"grams"`
+		Milliliters *float32 `json:"milliliters"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serving, err := data.NewFoodServing(food, body.Label, body.Grams, body.Milliliters)
+	if err != nil {
+		fmt.Println("Failed to extract serving details: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.CreateFoodServing(actor, *serving, version)
+	if err != nil {
+		fmt.Println("Failed to create serving: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Serving Created"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonBytes)
+}
+
+func (u *FoodHandler) DeleteFoodServing(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	food, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	serving, err := uuid.Parse(chi.URLParam(r, "servingId"))
+	if err != nil {
+		fmt.Println("Failed to parse serving id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.DeleteFoodServing(actor, food, serving, version)
+	if err != nil {
+		fmt.Println("Failed to delete serving: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Serving Deleted"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+var foodColumns = []string{"id", "timestamp", "user", "foodtype", "brand", "name", "kcal", "protein", "carbs", "fat", "saturated", "unsaturated", "fiber", "sugars", "barcodes", "nutrients"}
+
+func foodRecord(food data.FoodDto) []string {
+	nutrients, _ := json.Marshal(food.Nutrients)
+
+	return []string{
+		food.Id.String(),
+		food.Timestamp.Format(time.RFC3339Nano),
+		food.User.String(),
+		food.FoodType.String(),
+		food.Brand.String(),
+		food.Name,
+		lib.FormatFloat(food.KCAL),
+		lib.FormatFloat(food.Protein),
+		lib.FormatFloat(food.Carbs),
+		lib.FormatFloat(food.Fat),
+		lib.FormatFloat(food.Saturated),
+		lib.FormatFloat(food.Unsaturated),
+		lib.FormatFloat(food.Fiber),
+		lib.FormatFloat(food.Sugars),
+		strings.Join(food.Barcodes, " "),
+		string(nutrients),
+	}
+}
+
+// foodFromRecord builds a food from an import row. Blank barcodes or
+// nutrients leave those of an existing food untouched.
+func foodFromRecord(user uuid.UUID, record lib.Record) (*data.FoodDto, error) {
+	id, err := lib.ParseRecordUUID(record, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	foodtype, err := uuid.Parse(record("foodtype"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid foodtype: %w", err)
+	}
+
+	brand, err := uuid.Parse(record("brand"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid brand: %w", err)
+	}
+
+	var macros [8]float32
+	for i, key := range []string{"kcal", "protein", "carbs", "fat", "saturated", "unsaturated", "fiber", "sugars"} {
+		macros[i], err = lib.ParseRecordFloat(record, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var barcodes []string
+	if value := record("barcodes"); value != "" {
+		barcodes = strings.Fields(value)
+	}
+
+	var nutrients map[string]float32
+	if value := record("nutrients"); value != "" {
+		err = json.Unmarshal([]byte(value), &nutrients)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nutrients: %w", err)
+		}
+	}
+
+	food, err := data.NewFood(record("name"), macros[0], macros[1], macros[2], macros[3], macros[4], macros[5], macros[6], macros[7], nutrients, barcodes, user, foodtype, brand)
+	if err != nil {
+		return nil, err
+	}
+
+	food.Id = id
+	return food, nil
+}
+
+func (u *FoodHandler) ExportFoods(w http.ResponseWriter, r *http.Request) {
+	format, err := lib.ParseFormat(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse format: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseFoodFilter(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse filter: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	export, err := lib.NewExportWriter(w, format, "foods", foodColumns)
+	if err != nil {
+		fmt.Println("Failed to start export: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.ExportFoods(*filter, func(food data.FoodDto) error {
+		return export.Write(food, foodRecord(food))
+	})
+	if err == nil {
+		err = export.Flush()
+	}
+
+	// The status line is gone by now, so a failure can only cut the
+	// download short.
+	if err != nil {
+		fmt.Println("Failed to export foods: ", err)
+	}
+}
+
+func (u *FoodHandler) ImportFoods(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := lib.ParseFormat(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse format: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun, err := lib.ParseQueryBool(r.URL.Query(), "dryRun")
+	if err != nil {
+		fmt.Println("Failed to parse dry run: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rows []data.ImportRow[data.FoodDto]
+	count, rowErrors, err := lib.ReadRecords(r.Body, format, func(row int, record lib.Record) error {
+		food, err := foodFromRecord(actor.Id, record)
+		if err != nil {
+			return err
+		}
+
+		rows = append(rows, data.ImportRow[data.FoodDto]{Row: row, Value: *food})
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Failed to read import: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := u.Data.ImportFoods(actor, rows, data.ImportReport{Rows: count, DryRun: dryRun, Errors: rowErrors})
+	if err != nil {
+		fmt.Println("Failed to import foods: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeImportReport(w, report)
+}
+
+func (u *FoodHandler) BulkWriteFoods(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = data.BulkAtomic
+	}
+
+	if mode != data.BulkAtomic && mode != data.BulkBestEffort {
+		err = fmt.Errorf("invalid mode %q, must be %v or %v", mode, data.BulkAtomic, data.BulkBestEffort)
+		fmt.Println("Failed to parse mode: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body []json.RawMessage
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(body) == 0 || len(body) > maxBulkFoods {
+		err = fmt.Errorf("expected between 1 and %v foods, got %v", maxBulkFoods, len(body))
+		fmt.Println("Failed to extract food details: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Items that do not decode or validate are reported by index next to the
+	// ones the database rejects, instead of failing the whole request.
+	result := data.BulkResult{Mode: mode, Errors: []data.BulkItemError{}}
+	var items []data.BulkItem[data.FoodDto]
+	for i, raw := range body {
+		var item foodBody
+
+		err := json.Unmarshal(raw, &item)
+		if err != nil {
+			result.Errors = append(result.Errors, data.NewBulkItemError(i, err))
+			continue
+		}
+
+		id := uuid.Nil
+		if item.Id != "" {
+			id, err = uuid.Parse(item.Id)
+		}
+
+		var food *data.FoodDto
+		if err == nil {
+			food, err = item.food(actor.Id)
+		}
+
+		if err != nil {
+			result.Errors = append(result.Errors, data.NewBulkItemError(i, err))
+			continue
+		}
+
+		food.Id = id
+		food.Version = item.Version
+		items = append(items, data.BulkItem[data.FoodDto]{Index: i, Value: *food})
+	}
+
+	result, err = u.Data.BulkWriteFoods(actor, items, result)
+	if err != nil {
+		fmt.Println("Failed to write foods: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(result)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := http.StatusOK
+	if !result.Committed {
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonBytes)
+}
+
+// ListFoodTrash lists the food rows in the trash; sort=-deletedAt puts
+// the most recently deleted first.
+func (u *FoodHandler) ListFoodTrash(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, cursors, err := u.Data.ListFoodTrash(actor, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get food trash: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountFoodTrash(actor)
+		if err != nil {
+			fmt.Println("Failed to count food trash: ", err)
+			http.Error(w, err.Error(), lib.ErrorStatus(err))
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(rows, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *FoodHandler) RestoreFood(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	rowId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.RestoreFood(actor, rowId)
+	if err != nil {
+		fmt.Println("Failed to restore food: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Food Restored"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// ListFoodHistory lists the changes to a food, newest first.
+func (u *FoodHandler) ListFoodHistory(w http.ResponseWriter, r *http.Request) {
+	listHistory(w, r, u.Data, "food")
+}
+
+func (u *FoodHandler) RevertFood(w http.ResponseWriter, r *http.Request) {
+	revert(w, r, u.Data, "Food Reverted", u.Data.RevertFood)
+}
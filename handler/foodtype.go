@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/adamelfsborg-code/food/culinary/data"
 	"github.com/adamelfsborg-code/food/culinary/lib"
@@ -15,6 +17,20 @@ type FoodTypeHandler struct {
 	Data data.DataConn
 }
 
+func parseFoodTypeFilter(query url.Values) (*data.FoodTypeFilterDto, error) {
+	filterId, err := lib.ParseQueryUUID(query, "id")
+	if err != nil {
+		return nil, err
+	}
+
+	filterCategory, err := lib.ParseQueryUUID(query, "category")
+	if err != nil {
+		return nil, err
+	}
+
+	return data.NewFoodTypeFilterDto(filterId, query.Get("name"), filterCategory)
+}
+
 func (u *FoodTypeHandler) GetFoodTypeById(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
@@ -32,6 +48,10 @@ func (u *FoodTypeHandler) GetFoodTypeById(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if lib.NotModified(w, r, lib.ETag(foodTypes.Version)) {
+		return
+	}
+
 	jsonBytes, err := json.Marshal(foodTypes)
 	if err != nil {
 		fmt.Println("Failed to decode json: ", err)
@@ -45,30 +65,38 @@ func (u *FoodTypeHandler) GetFoodTypeById(w http.ResponseWriter, r *http.Request
 }
 
 func (u *FoodTypeHandler) ListFoodTypes(w http.ResponseWriter, r *http.Request) {
-	pageIndex := r.URL.Query().Get("pageIndex")
-	pageSize := r.URL.Query().Get("pageSize")
-
-	pagination, err := lib.NewPagination(pageIndex, pageSize)
+	pagination, err := lib.ParsePagination(r.URL.Query())
 	if err != nil {
 		fmt.Println("Failed to parse pagination: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	foodTypes, err := u.Data.ListFoodTypes(pagination.PageIndex, pagination.PageSize)
+	filter, err := parseFoodTypeFilter(r.URL.Query())
 	if err != nil {
-		fmt.Println("Failed to get foodType: ", err)
+		fmt.Println("Failed to parse filter: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	count, err := u.Data.CountFoodTypes()
+	foodTypes, cursors, err := u.Data.ListFoodTypes(*filter, pagination.Page())
 	if err != nil {
-		fmt.Println("Failed to get brand: ", err)
+		fmt.Println("Failed to get foodType: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountFoodTypes(*filter)
+		if err != nil {
+			fmt.Println("Failed to get brand: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
 	response := lib.NewPaginatedResponse(foodTypes, count, *pagination)
 
 	jsonBytes, err := json.Marshal(response)
@@ -84,9 +112,7 @@ func (u *FoodTypeHandler) ListFoodTypes(w http.ResponseWriter, r *http.Request)
 }
 
 func (u *FoodTypeHandler) CreateFoodType(w http.ResponseWriter, r *http.Request) {
-	headerId := r.Header.Get("X-USER-ID")
-
-	userId, err := uuid.Parse(headerId)
+	actor, err := lib.ActorFromRequest(r)
 	if err != nil {
 		fmt.Println("Failed to parse id: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -112,14 +138,14 @@ func (u *FoodTypeHandler) CreateFoodType(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	foodType, err := data.NewFoodType(userId, body.Name, category)
+	foodType, err := data.NewFoodType(actor.Id, body.Name, category)
 	if err != nil {
 		fmt.Println("Failed to extract foodType details: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = u.Data.CreateFoodType(*foodType)
+	err = u.Data.CreateFoodType(actor, *foodType)
 	if err != nil {
 		fmt.Println("Failed to create foodType: ", err)
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -139,6 +165,13 @@ func (u *FoodTypeHandler) CreateFoodType(w http.ResponseWriter, r *http.Request)
 }
 
 func (u *FoodTypeHandler) EditFoodType(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := chi.URLParam(r, "id")
 
 	foodType, err := uuid.Parse(id)
@@ -167,10 +200,17 @@ func (u *FoodTypeHandler) EditFoodType(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = u.Data.EditFoodType(foodType, body.Name, category)
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.EditFoodType(actor, foodType, version, body.Name, category)
 	if err != nil {
 		fmt.Println("Failed to delete foodType: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -187,6 +227,13 @@ func (u *FoodTypeHandler) EditFoodType(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *FoodTypeHandler) DeleteFoodType(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	id := chi.URLParam(r, "id")
 
 	foodType, err := uuid.Parse(id)
@@ -196,10 +243,17 @@ func (u *FoodTypeHandler) DeleteFoodType(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = u.Data.DeleteFoodType(foodType)
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.DeleteFoodType(actor, foodType, version)
 	if err != nil {
 		fmt.Println("Failed to delete foodType: ", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
 		return
 	}
 
@@ -214,3 +268,198 @@ func (u *FoodTypeHandler) DeleteFoodType(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 }
+
+var foodTypeColumns = []string{"id", "timestamp", "user", "category", "name"}
+
+func foodTypeRecord(foodType data.FoodTypeDto) []string {
+	return []string{foodType.Id.String(), foodType.Timestamp.Format(time.RFC3339Nano), foodType.User.String(), foodType.Category.String(), foodType.Name}
+}
+
+func (u *FoodTypeHandler) ExportFoodTypes(w http.ResponseWriter, r *http.Request) {
+	format, err := lib.ParseFormat(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse format: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseFoodTypeFilter(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse filter: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	export, err := lib.NewExportWriter(w, format, "foodtypes", foodTypeColumns)
+	if err != nil {
+		fmt.Println("Failed to start export: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.ExportFoodTypes(*filter, func(foodType data.FoodTypeDto) error {
+		return export.Write(foodType, foodTypeRecord(foodType))
+	})
+	if err == nil {
+		err = export.Flush()
+	}
+
+	// The status line is gone by now, so a failure can only cut the
+	// download short.
+	if err != nil {
+		fmt.Println("Failed to export food types: ", err)
+	}
+}
+
+func (u *FoodTypeHandler) ImportFoodTypes(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format, err := lib.ParseFormat(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse format: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun, err := lib.ParseQueryBool(r.URL.Query(), "dryRun")
+	if err != nil {
+		fmt.Println("Failed to parse dry run: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rows []data.ImportRow[data.FoodTypeDto]
+	count, rowErrors, err := lib.ReadRecords(r.Body, format, func(row int, record lib.Record) error {
+		id, err := lib.ParseRecordUUID(record, "id")
+		if err != nil {
+			return err
+		}
+
+		category, err := uuid.Parse(record("category"))
+		if err != nil {
+			return fmt.Errorf("invalid category: %w", err)
+		}
+
+		foodType, err := data.NewFoodType(actor.Id, record("name"), category)
+		if err != nil {
+			return err
+		}
+
+		foodType.Id = id
+		rows = append(rows, data.ImportRow[data.FoodTypeDto]{Row: row, Value: *foodType})
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Failed to read import: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := u.Data.ImportFoodTypes(actor, rows, data.ImportReport{Rows: count, DryRun: dryRun, Errors: rowErrors})
+	if err != nil {
+		fmt.Println("Failed to import food types: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	writeImportReport(w, report)
+}
+
+// ListFoodTypeTrash lists the food type rows in the trash; sort=-deletedAt puts
+// the most recently deleted first.
+func (u *FoodTypeHandler) ListFoodTypeTrash(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, cursors, err := u.Data.ListFoodTypeTrash(actor, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get food type trash: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountFoodTypeTrash(actor)
+		if err != nil {
+			fmt.Println("Failed to count food type trash: ", err)
+			http.Error(w, err.Error(), lib.ErrorStatus(err))
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(rows, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *FoodTypeHandler) RestoreFoodType(w http.ResponseWriter, r *http.Request) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	rowId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.RestoreFoodType(actor, rowId)
+	if err != nil {
+		fmt.Println("Failed to restore food type: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Food Type Restored"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// ListFoodTypeHistory lists the changes to a food type, newest first.
+func (u *FoodTypeHandler) ListFoodTypeHistory(w http.ResponseWriter, r *http.Request) {
+	listHistory(w, r, u.Data, "foodtype")
+}
+
+func (u *FoodTypeHandler) RevertFoodType(w http.ResponseWriter, r *http.Request) {
+	revert(w, r, u.Data, "FoodType Reverted", u.Data.RevertFoodType)
+}
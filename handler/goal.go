@@ -0,0 +1,378 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/lib"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type GoalHandler struct {
+	Data data.DataConn
+}
+
+// today returns the current date in the time zone passed as tz.
+func today(r *http.Request) (time.Time, *time.Location, error) {
+	loc, err := lib.ParseQueryLocation(r.URL.Query(), "tz")
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	return time.Now().In(loc), loc, nil
+}
+
+func parseDate(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	return time.Parse(time.DateOnly, value)
+}
+
+func (u *GoalHandler) ListGoals(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	goals, cursors, err := u.Data.ListGoals(userId, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get goals: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountGoals(userId)
+		if err != nil {
+			fmt.Println("Failed to count goals: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(goals, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *GoalHandler) GetCurrentGoal(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now, _, err := today(r)
+	if err != nil {
+		fmt.Println("Failed to parse time zone: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	date, err := parseDate(r.URL.Query().Get("date"), now)
+	if err != nil {
+		fmt.Println("Failed to parse date: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	goal, err := u.Data.GetGoalAt(userId, date)
+	if err != nil {
+		fmt.Println("Failed to get goal: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(goal)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *GoalHandler) GetGoalProgress(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now, loc, err := today(r)
+	if err != nil {
+		fmt.Println("Failed to parse time zone: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := parseDate(r.URL.Query().Get("from"), now)
+	if err != nil {
+		fmt.Println("Failed to parse from: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := parseDate(r.URL.Query().Get("to"), from)
+	if err != nil {
+		fmt.Println("Failed to parse to: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	progress, err := u.Data.GetGoalProgress(userId, from, to, loc)
+	if err != nil {
+		fmt.Println("Failed to get goal progress: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(progress)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *GoalHandler) CreateGoal(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		EffectiveFrom string   `json:"effectiveFrom"`
+		KCAL          *float32 `json:"kcal"`
+		Protein       *float32 `json:"protein"`
+		Carbs         *float32 `json:"carbs"`
+		Fat           *float32 `json:"fat"`
+		Fiber         *float32 `json:"fiber"`
+		Sugars        *float32 `json:"sugars"`
+	}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now, _, err := today(r)
+	if err != nil {
+		fmt.Println("Failed to parse time zone: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	effectiveFrom, err := parseDate(body.EffectiveFrom, now)
+	if err != nil {
+		fmt.Println("Failed to parse effective from: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	goal, err := data.NewGoal(userId, effectiveFrom, body.KCAL, body.Protein, body.Carbs, body.Fat, body.Fiber, body.Sugars)
+	if err != nil {
+		fmt.Println("Failed to extract goal details: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.CreateGoal(*goal)
+	if err != nil {
+		fmt.Println("Failed to create goal: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Goal Created"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(jsonBytes)
+}
+
+func (u *GoalHandler) DeleteGoal(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	goalId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := lib.IfMatch(r, u.Data.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = u.Data.DeleteGoal(userId, goalId, version)
+	if err != nil {
+		fmt.Println("Failed to delete goal: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Goal Deleted"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// ListGoalTrash lists the goal rows in the trash; sort=-deletedAt puts
+// the most recently deleted first.
+func (u *GoalHandler) ListGoalTrash(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, cursors, err := u.Data.ListGoalTrash(userId, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get goal trash: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountGoalTrash(userId)
+		if err != nil {
+			fmt.Println("Failed to count goal trash: ", err)
+			http.Error(w, err.Error(), lib.ErrorStatus(err))
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(rows, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *GoalHandler) RestoreGoal(w http.ResponseWriter, r *http.Request) {
+	headerId := r.Header.Get("X-USER-ID")
+
+	userId, err := uuid.Parse(headerId)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	rowId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.RestoreGoal(userId, rowId)
+	if err != nil {
+		fmt.Println("Failed to restore goal: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Goal Restored"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
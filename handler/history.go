@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/lib"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// listHistory answers with the paginated history of the entity whose id is
+// in the path, as served by the GET /{id}/history routes.
+func listHistory(w http.ResponseWriter, r *http.Request, d data.DataConn, entity string) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, cursors, err := d.ListHistory(entity, id, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get history: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = d.CountHistory(entity, id)
+		if err != nil {
+			fmt.Println("Failed to count history: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(rows, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+// revert calls fn with the caller, the id in the path, the change to revert
+// to and the version of the entity If-Match makes the revert conditional on,
+// and answers with message, as served by the
+// POST /{id}/history/{change}/revert routes.
+func revert(w http.ResponseWriter, r *http.Request, d data.DataConn, message string, fn func(actor data.Actor, id, change uuid.UUID, version int) error) {
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	change, err := uuid.Parse(chi.URLParam(r, "change"))
+	if err != nil {
+		fmt.Println("Failed to parse change: ", err)
+		http.Error(w, "Failed to parse change", http.StatusBadRequest)
+		return
+	}
+
+	version, err := lib.IfMatch(r, d.Env.RequireIfMatch)
+	if err != nil {
+		fmt.Println("Failed to parse If-Match: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	err = fn(actor, id, change, version)
+	if err != nil {
+		fmt.Println("Failed to revert: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": message})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/importer"
+	"github.com/adamelfsborg-code/food/culinary/lib"
+)
+
+type ImportHandler struct {
+	Data data.DataConn
+}
+
+func (u *ImportHandler) ImportOpenFoodFacts(w http.ResponseWriter, r *http.Request) {
+	if u.Data.Env.ImportDir == "" {
+		http.Error(w, "import is disabled, set IMPORT_DIR", http.StatusForbidden)
+		return
+	}
+
+	actor, err := lib.ActorFromRequest(r)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := struct {
+		File   string `json:"file"`
+		Update *bool  `json:"update"`
+		Limit  int    `json:"limit"`
+	}{}
+
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The file is resolved inside IMPORT_DIR only; fs.ValidPath rejects
+	// absolute paths and any ".." element.
+	if !fs.ValidPath(body.File) || body.File == "." {
+		http.Error(w, fmt.Sprintf("invalid file %q", body.File), http.StatusBadRequest)
+		return
+	}
+
+	source, file, err := importer.Open(os.DirFS(u.Data.Env.ImportDir), body.File)
+	if err != nil {
+		fmt.Println("Failed to open import file: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	im := importer.New(&u.Data, actor)
+	im.Limit = body.Limit
+	if body.Update != nil {
+		im.Update = *body.Update
+	}
+
+	report, err := im.Run(r.Context(), source)
+	if err != nil {
+		fmt.Println("Failed to import: ", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonBytes, err := json.Marshal(report)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
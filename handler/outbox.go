@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/lib"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type OutboxHandler struct {
+	Data data.DataConn
+}
+
+// ListOutbox lists outbox events, dead ones unless status says otherwise.
+func (u *OutboxHandler) ListOutbox(w http.ResponseWriter, r *http.Request) {
+	pagination, err := lib.ParsePagination(r.URL.Query())
+	if err != nil {
+		fmt.Println("Failed to parse pagination: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := data.OutboxDead
+	if r.URL.Query().Has("status") {
+		status = r.URL.Query().Get("status")
+	}
+
+	filter, err := data.NewOutboxFilterDto(status, r.URL.Query().Get("type"))
+	if err != nil {
+		fmt.Println("Failed to parse filter: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, cursors, err := u.Data.ListOutbox(*filter, pagination.Page())
+	if err != nil {
+		fmt.Println("Failed to get outbox: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	count := 0
+	if !pagination.SkipCount {
+		count, err = u.Data.CountOutbox(*filter)
+		if err != nil {
+			fmt.Println("Failed to get outbox: ", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pagination.SetCursors(cursors)
+	response := lib.NewPaginatedResponse(rows, count, *pagination)
+
+	jsonBytes, err := json.Marshal(response)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
+
+func (u *OutboxHandler) RetryOutbox(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	eventId, err := uuid.Parse(id)
+	if err != nil {
+		fmt.Println("Failed to parse id: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = u.Data.RetryOutbox(eventId)
+	if err != nil {
+		fmt.Println("Failed to retry event: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(map[string]string{"message": "Event Queued"})
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/lib"
+)
+
+type TrashHandler struct {
+	Data data.DataConn
+}
+
+// PurgeTrash deletes the rows kept in the trash longer than TRASH_RETENTION
+// right away instead of waiting for the hourly purge, and answers with the
+// number deleted per table.
+func (u *TrashHandler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	purged, err := u.Data.PurgeTrash(r.Context(), time.Now().Add(-u.Data.Env.TrashRetention))
+	if err != nil {
+		fmt.Println("Failed to purge trash: ", err)
+		http.Error(w, err.Error(), lib.ErrorStatus(err))
+		return
+	}
+
+	jsonBytes, err := json.Marshal(purged)
+	if err != nil {
+		fmt.Println("Failed to decode json: ", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonBytes)
+}
@@ -0,0 +1,263 @@
+// Package importer loads Open Food Facts exports into the food catalogue.
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/gtin"
+	"github.com/google/uuid"
+)
+
+// Names used when a product leaves its brand or categories blank.
+const (
+	DefaultBrand    = "Generic"
+	DefaultCategory = "Other"
+)
+
+// maxReportedErrors caps Report.Errors so a broken dump does not produce an
+// unbounded response.
+const maxReportedErrors = 100
+
+type RowError struct {
+	Row   int    `json:"row"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error"`
+}
+
+type Report struct {
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Skipped int        `json:"skipped"`
+	Invalid int        `json:"invalid"`
+	Errors  []RowError `json:"errors"`
+}
+
+func (r *Report) invalid(row int, code string, err error) {
+	r.Invalid++
+	if len(r.Errors) < maxReportedErrors {
+		r.Errors = append(r.Errors, RowError{Row: row, Code: code, Error: err.Error()})
+	}
+}
+
+type outcome int
+
+const (
+	created outcome = iota
+	updated
+	skipped
+)
+
+type Importer struct {
+	Data *data.DataConn
+	// Actor is recorded as the creator of new rows. Existing foods are only
+	// refreshed when it may edit them, and products that need a category or
+	// food type that does not exist yet are invalid unless it may write the
+	// taxonomy.
+	Actor data.Actor
+	// Update refreshes the nutrition of foods that are already in the
+	// catalogue instead of skipping them.
+	Update bool
+	// Limit stops the import after that many rows, 0 reads everything.
+	Limit int
+
+	brands     map[string]uuid.UUID
+	categories map[string]uuid.UUID
+	foodTypes  map[string]uuid.UUID
+}
+
+func New(d *data.DataConn, actor data.Actor) *Importer {
+	return &Importer{
+		Data:       d,
+		Actor:      actor,
+		Update:     true,
+		brands:     map[string]uuid.UUID{},
+		categories: map[string]uuid.UUID{},
+		foodTypes:  map[string]uuid.UUID{},
+	}
+}
+
+// Run imports every product from src. Rows that cannot be parsed or stored
+// are counted as invalid and do not stop the import; errors reading src do,
+// and are returned together with the report so far.
+func (im *Importer) Run(ctx context.Context, src Source) (Report, error) {
+	report := Report{Errors: []RowError{}}
+
+	for row := 1; im.Limit == 0 || row <= im.Limit; row++ {
+		err := ctx.Err()
+		if err != nil {
+			return report, err
+		}
+
+		product, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if errors.Is(err, ErrInvalidRow) {
+			report.invalid(row, product.Code, err)
+			continue
+		}
+
+		if err != nil {
+			return report, err
+		}
+
+		result, err := im.importProduct(product)
+		if err != nil {
+			report.invalid(row, product.Code, err)
+			continue
+		}
+
+		switch result {
+		case created:
+			report.Created++
+		case updated:
+			report.Updated++
+		case skipped:
+			report.Skipped++
+		}
+	}
+
+	return report, nil
+}
+
+func (im *Importer) importProduct(product Product) (outcome, error) {
+	var barcodes []string
+	barcode, err := gtin.Normalize(product.Code)
+	if err == nil {
+		barcodes = []string{barcode}
+	}
+
+	// Store internal codes are not GTINs; such products are still imported
+	// and deduplicated by brand and name only.
+	if err != nil {
+		barcode = ""
+	}
+
+	brandName := product.Brand
+	if brandName == "" {
+		brandName = DefaultBrand
+	}
+
+	brand, err := im.ensure(im.brands, brandName, func() (uuid.UUID, error) {
+		return im.Data.EnsureBrand(im.Actor, brandName)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n := product.Nutrition
+	food, err := data.NewFood(product.Name, n.KCAL, n.Protein, n.Carbs, n.Fat, n.Saturated, n.Unsaturated, n.Fiber, n.Sugars, product.Nutrients, barcodes, im.Actor.Id, uuid.Nil, brand)
+	if err != nil {
+		return 0, err
+	}
+
+	existing, err := im.Data.FindFood(barcode, brand, product.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	if existing != nil {
+		return im.updateFood(*existing, *food)
+	}
+
+	food.FoodType, err = im.foodType(product)
+	if err != nil {
+		return 0, err
+	}
+
+	err = im.Data.CreateFood(im.Actor, *food)
+	if err != nil {
+		return 0, err
+	}
+
+	return created, nil
+}
+
+// updateFood refreshes the nutrition of an existing food and adds the
+// imported nutrients and barcodes to its own, keeping its name, brand and
+// food type as curated.
+func (im *Importer) updateFood(existing, imported data.FoodDto) (outcome, error) {
+	if !im.Update {
+		return skipped, nil
+	}
+
+	nutrients := maps.Clone(existing.Nutrients)
+	if nutrients == nil {
+		nutrients = map[string]float32{}
+	}
+	maps.Copy(nutrients, imported.Nutrients)
+
+	barcodes := slices.Clone(existing.Barcodes)
+	for _, code := range imported.Barcodes {
+		if !slices.Contains(barcodes, code) {
+			barcodes = append(barcodes, code)
+		}
+	}
+
+	if existing.Nutrition() == imported.Nutrition() &&
+		maps.Equal(existing.Nutrients, nutrients) &&
+		len(existing.Barcodes) == len(barcodes) {
+		return skipped, nil
+	}
+
+	// At the version read, so an edit made meanwhile is not overwritten with
+	// the values merged above.
+	n := imported.Nutrition()
+	err := im.Data.EditFood(im.Actor, existing.Name, n.KCAL, n.Protein, n.Carbs, n.Fat, n.Saturated, n.Unsaturated, n.Fiber, n.Sugars, nutrients, barcodes, existing.Brand, existing.FoodType, existing.Id, existing.Version)
+	if errors.Is(err, data.ErrForbidden) {
+		return skipped, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return updated, nil
+}
+
+func (im *Importer) foodType(product Product) (uuid.UUID, error) {
+	categoryName := product.Category
+	if categoryName == "" {
+		categoryName = DefaultCategory
+	}
+
+	typeName := product.FoodType
+	if typeName == "" {
+		typeName = categoryName
+	}
+
+	category, err := im.ensure(im.categories, categoryName, func() (uuid.UUID, error) {
+		return im.Data.EnsureCategory(im.Actor, categoryName)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return im.ensure(im.foodTypes, typeName, func() (uuid.UUID, error) {
+		return im.Data.EnsureFoodType(im.Actor, typeName, category)
+	})
+}
+
+// ensure memoizes create-or-get lookups by case-insensitive name, since a
+// dump repeats the same brands and categories for thousands of products.
+func (im *Importer) ensure(cache map[string]uuid.UUID, name string, lookup func() (uuid.UUID, error)) (uuid.UUID, error) {
+	key := strings.ToLower(name)
+	if id, ok := cache[key]; ok {
+		return id, nil
+	}
+
+	id, err := lookup()
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	cache[key] = id
+	return id, nil
+}
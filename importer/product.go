@@ -0,0 +1,205 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+)
+
+// Product is one Open Food Facts product mapped onto the catalogue. Values
+// are per 100 g.
+type Product struct {
+	Code      string
+	Name      string
+	Brand     string
+	Category  string
+	FoodType  string
+	Nutrition data.NutritionDto
+	Nutrients map[string]float32
+}
+
+// kcalPerKJ converts kilojoules to kilocalories.
+const kcalPerKJ = 1 / 4.184
+
+// offNutrients maps Open Food Facts nutriment keys, always reported in grams,
+// onto the nutrient catalogue seeded in 0007_nutrient and the factor that
+// converts grams to the catalogue unit.
+var offNutrients = map[string]struct {
+	code   string
+	factor float64
+}{
+	"salt":                {"salt", 1},
+	"sodium":              {"sodium", 1e3},
+	"potassium":           {"potassium", 1e3},
+	"calcium":             {"calcium", 1e3},
+	"magnesium":           {"magnesium", 1e3},
+	"iron":                {"iron", 1e3},
+	"zinc":                {"zinc", 1e3},
+	"vitamin-a":           {"vitamin_a", 1e6},
+	"vitamin-b12":         {"vitamin_b12", 1e6},
+	"vitamin-c":           {"vitamin_c", 1e3},
+	"vitamin-d":           {"vitamin_d", 1e6},
+	"vitamin-e":           {"vitamin_e", 1e3},
+	"vitamin-k":           {"vitamin_k", 1e6},
+	"folates":             {"folate", 1e6},
+	"cholesterol":         {"cholesterol", 1e3},
+	"trans-fat":           {"trans_fat", 1},
+	"monounsaturated-fat": {"monounsaturated", 1},
+	"polyunsaturated-fat": {"polyunsaturated", 1},
+}
+
+// fields looks up a product field or nutriment by its Open Food Facts name,
+// e.g. "product_name" or "proteins_100g".
+type fields func(key string) string
+
+// amount reads a per 100 g nutriment in grams, or kJ/kcal for energy.
+func (get fields) amount(name string) (float64, bool, error) {
+	value := strings.TrimSpace(get(name + "_100g"))
+	if value == "" {
+		return 0, false, nil
+	}
+
+	amount, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%v: %w", name, err)
+	}
+
+	if amount < 0 {
+		return 0, false, fmt.Errorf("%v must not be negative", name)
+	}
+
+	return amount, true, nil
+}
+
+func parseProduct(get fields) (Product, error) {
+	product := Product{
+		Code:      strings.TrimSpace(get("code")),
+		Name:      firstNonEmpty(get("product_name"), get("product_name_en"), get("generic_name")),
+		Brand:     firstListItem(get("brands")),
+		Nutrients: map[string]float32{},
+	}
+
+	tags := splitList(get("categories_tags"))
+	if len(tags) > 0 {
+		product.Category = tagName(tags[0])
+		product.FoodType = tagName(tags[len(tags)-1])
+	}
+
+	if product.Name == "" {
+		return product, fmt.Errorf("product has no name")
+	}
+
+	macros := map[string]*float32{
+		"proteins":      &product.Nutrition.Protein,
+		"carbohydrates": &product.Nutrition.Carbs,
+		"fat":           &product.Nutrition.Fat,
+		"saturated-fat": &product.Nutrition.Saturated,
+		"fiber":         &product.Nutrition.Fiber,
+		"sugars":        &product.Nutrition.Sugars,
+	}
+
+	for name, field := range macros {
+		amount, _, err := get.amount(name)
+		if err != nil {
+			return product, err
+		}
+		*field = float32(amount)
+	}
+
+	kcal, err := energy(get)
+	if err != nil {
+		return product, err
+	}
+	product.Nutrition.KCAL = float32(kcal)
+
+	for name, nutrient := range offNutrients {
+		amount, ok, err := get.amount(name)
+		if err != nil {
+			return product, err
+		}
+
+		if ok {
+			product.Nutrients[nutrient.code] = float32(amount * nutrient.factor)
+		}
+	}
+
+	// The legacy column has no direct counterpart; prefer the split values
+	// and fall back to what is left of the fat after the saturated part.
+	mono, hasMono := product.Nutrients["monounsaturated"]
+	poly, hasPoly := product.Nutrients["polyunsaturated"]
+	switch {
+	case hasMono || hasPoly:
+		product.Nutrition.Unsaturated = mono + poly
+	case product.Nutrition.Fat > product.Nutrition.Saturated:
+		product.Nutrition.Unsaturated = product.Nutrition.Fat - product.Nutrition.Saturated
+	}
+
+	return product, nil
+}
+
+// energy returns kcal per 100 g, converting from kJ when the product only
+// reports that. Open Food Facts' plain "energy" is in kJ.
+func energy(get fields) (float64, error) {
+	kcal, ok, err := get.amount("energy-kcal")
+	if err != nil || ok {
+		return kcal, err
+	}
+
+	for _, name := range []string{"energy-kj", "energy"} {
+		kj, ok, err := get.amount(name)
+		if err != nil || ok {
+			return kj * kcalPerKJ, err
+		}
+	}
+
+	return 0, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func firstListItem(value string) string {
+	items := splitList(value)
+	if len(items) == 0 {
+		return ""
+	}
+
+	return items[0]
+}
+
+// tagName turns a taxonomy tag such as "en:plant-based-foods" into
+// "Plant based foods".
+func tagName(tag string) string {
+	if _, name, ok := strings.Cut(tag, ":"); ok {
+		tag = name
+	}
+
+	tag = strings.TrimSpace(strings.ReplaceAll(tag, "-", " "))
+	if tag == "" {
+		return ""
+	}
+
+	return strings.ToUpper(tag[:1]) + tag[1:]
+}
@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"math"
+	"testing"
+)
+
+// product builds the fields of a product from a map, as the sources do from
+// a CSV row or JSON object.
+func product(values map[string]string) fields {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-3
+}
+
+func TestEnergy(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    float64
+		wantErr bool
+	}{
+		{name: "kcal", values: map[string]string{"energy-kcal_100g": "380"}, want: 380},
+		{name: "kJ", values: map[string]string{"energy-kj_100g": "1590"}, want: 1590 / 4.184},
+		{name: "plain energy is kJ", values: map[string]string{"energy_100g": "418.4"}, want: 100},
+		{name: "kcal preferred over kJ", values: map[string]string{"energy-kcal_100g": "380", "energy-kj_100g": "1000", "energy_100g": "1000"}, want: 380},
+		{name: "kJ preferred over plain energy", values: map[string]string{"energy-kj_100g": "418.4", "energy_100g": "1000"}, want: 100},
+		{name: "decimal comma", values: map[string]string{"energy-kcal_100g": "38,5"}, want: 38.5},
+		{name: "none", values: map[string]string{}, want: 0},
+		{name: "garbage", values: map[string]string{"energy-kcal_100g": "lots"}, wantErr: true},
+		{name: "negative", values: map[string]string{"energy-kj_100g": "-1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := energy(product(tt.values))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("energy = %v, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("energy: %v", err)
+			}
+
+			if !near(got, tt.want) {
+				t.Errorf("energy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProduct(t *testing.T) {
+	base := func(extra map[string]string) map[string]string {
+		values := map[string]string{
+			"code":                 "4006381333931",
+			"product_name":         "Rolled oats",
+			"brands":               "Oatly, Other",
+			"categories_tags":      "en:plant-based-foods,en:cereals,en:rolled-oats",
+			"proteins_100g":        "13",
+			"carbohydrates_100g":   "60",
+			"fat_100g":             "7",
+			"saturated-fat_100g":   "1.2",
+			"fiber_100g":           "10",
+			"sugars_100g":          "1",
+			"energy-kcal_100g":     "370",
+			"sodium_100g":          "0.006",
+			"vitamin-b12_100g":     "0.0000005",
+			"unknown-thing_100g":   "3",
+			"energy-kj_100g":       "1548",
+			"product_name_en":      "Oats",
+			"generic_name":         "Oats",
+			"categories_hierarchy": "ignored",
+		}
+
+		for key, value := range extra {
+			values[key] = value
+		}
+
+		return values
+	}
+
+	t.Run("mapped", func(t *testing.T) {
+		p, err := parseProduct(product(base(nil)))
+		if err != nil {
+			t.Fatalf("parseProduct: %v", err)
+		}
+
+		if p.Code != "4006381333931" || p.Name != "Rolled oats" || p.Brand != "Oatly" {
+			t.Errorf("got code %q, name %q, brand %q", p.Code, p.Name, p.Brand)
+		}
+
+		if p.Category != "Plant based foods" || p.FoodType != "Rolled oats" {
+			t.Errorf("got category %q, food type %q", p.Category, p.FoodType)
+		}
+
+		n := p.Nutrition
+		if n.KCAL != 370 || n.Protein != 13 || n.Carbs != 60 || n.Fat != 7 || n.Saturated != 1.2 || n.Fiber != 10 || n.Sugars != 1 {
+			t.Errorf("got nutrition %+v", n)
+		}
+
+		if !near(float64(p.Nutrients["sodium"]), 6) || !near(float64(p.Nutrients["vitamin_b12"]), 0.5) {
+			t.Errorf("got nutrients %v", p.Nutrients)
+		}
+
+		if len(p.Nutrients) != 2 {
+			t.Errorf("got %v nutrients, want 2: %v", len(p.Nutrients), p.Nutrients)
+		}
+	})
+
+	tests := []struct {
+		name            string
+		values          map[string]string
+		wantUnsaturated float32
+		wantErr         bool
+	}{
+		{name: "unsaturated from mono and poly", values: base(map[string]string{"monounsaturated-fat_100g": "2.5", "polyunsaturated-fat_100g": "3"}), wantUnsaturated: 5.5},
+		{name: "unsaturated from mono only", values: base(map[string]string{"monounsaturated-fat_100g": "2.5"}), wantUnsaturated: 2.5},
+		{name: "unsaturated from the rest of the fat", values: base(nil), wantUnsaturated: 5.8},
+		{name: "no unsaturated when saturated exceeds fat", values: base(map[string]string{"saturated-fat_100g": "8"}), wantUnsaturated: 0},
+		{name: "name falls back to English", values: base(map[string]string{"product_name": " "}), wantUnsaturated: 5.8},
+		{name: "no name", values: base(map[string]string{"product_name": "", "product_name_en": "", "generic_name": ""}), wantErr: true},
+		{name: "negative macro", values: base(map[string]string{"fat_100g": "-1"}), wantErr: true},
+		{name: "garbage macro", values: base(map[string]string{"proteins_100g": "n/a"}), wantErr: true},
+		{name: "garbage nutrient", values: base(map[string]string{"iron_100g": "trace"}), wantErr: true},
+		{name: "negative nutrient", values: base(map[string]string{"zinc_100g": "-0.1"}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseProduct(product(tt.values))
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseProduct succeeded with %+v", p)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseProduct: %v", err)
+			}
+
+			if !near(float64(p.Nutrition.Unsaturated), float64(tt.wantUnsaturated)) {
+				t.Errorf("unsaturated = %v, want %v", p.Nutrition.Unsaturated, tt.wantUnsaturated)
+			}
+		})
+	}
+}
+
+func TestParseProductWithoutCategories(t *testing.T) {
+	p, err := parseProduct(product(map[string]string{"product_name": "Water"}))
+	if err != nil {
+		t.Fatalf("parseProduct: %v", err)
+	}
+
+	if p.Category != "" || p.FoodType != "" || p.Brand != "" {
+		t.Errorf("got category %q, food type %q, brand %q", p.Category, p.FoodType, p.Brand)
+	}
+}
+
+func TestTagName(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want string
+	}{
+		{"en:plant-based-foods", "Plant based foods"},
+		{"fr:produits-laitiers", "Produits laitiers"},
+		{"cereals", "Cereals"},
+		{"en:", ""},
+		{"", ""},
+		{"en:-", ""},
+		{"en:100-juice", "100 juice"},
+		{"en:a:b", "A:b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got := tagName(tt.tag)
+			if got != tt.want {
+				t.Errorf("tagName(%q) = %q, want %q", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidRow marks a row that could not be parsed. The source can carry on
+// with the next row after returning it.
+var ErrInvalidRow = errors.New("invalid row")
+
+// Source yields products until it returns io.EOF.
+type Source interface {
+	Next() (Product, error)
+}
+
+type jsonlSource struct {
+	reader *bufio.Reader
+}
+
+// NewJSONLSource reads the Open Food Facts JSONL export, one product object
+// per line.
+func NewJSONLSource(r io.Reader) Source {
+	return &jsonlSource{reader: bufio.NewReaderSize(r, 1<<20)}
+}
+
+func (s *jsonlSource) Next() (Product, error) {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return Product{}, err
+			}
+			continue
+		}
+
+		if err != nil && err != io.EOF {
+			return Product{}, err
+		}
+
+		var raw map[string]any
+		jsonErr := json.Unmarshal(line, &raw)
+		if jsonErr != nil {
+			return Product{}, fmt.Errorf("%w: %v", ErrInvalidRow, jsonErr)
+		}
+
+		nutriments, _ := raw["nutriments"].(map[string]any)
+		product, err := parseProduct(func(key string) string {
+			if value, ok := raw[key]; ok {
+				return jsonString(value)
+			}
+			return jsonString(nutriments[key])
+		})
+		if err != nil {
+			return product, fmt.Errorf("%w: %v", ErrInvalidRow, err)
+		}
+
+		return product, nil
+	}
+}
+
+func jsonString(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, jsonString(item))
+		}
+		return strings.Join(items, ",")
+	default:
+		return ""
+	}
+}
+
+type csvSource struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// NewCSVSource reads the Open Food Facts CSV export. The header decides the
+// delimiter, since the official dump is tab separated despite its name.
+func NewCSVSource(r io.Reader) (Source, error) {
+	buffered := bufio.NewReaderSize(r, 1<<20)
+
+	header, err := buffered.Peek(buffered.Size())
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	if i := bytes.IndexByte(header, '\n'); i >= 0 {
+		header = header[:i]
+	}
+
+	reader := csv.NewReader(buffered)
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	if bytes.IndexByte(header, '\t') >= 0 {
+		reader.Comma = '\t'
+	}
+
+	names, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(names))
+	for i, name := range names {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	return &csvSource{reader: reader, columns: columns}, nil
+}
+
+func (s *csvSource) Next() (Product, error) {
+	record, err := s.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Product{}, fmt.Errorf("%w: %v", ErrInvalidRow, err)
+		}
+		return Product{}, err
+	}
+
+	product, err := parseProduct(func(key string) string {
+		i, ok := s.columns[key]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	})
+	if err != nil {
+		return product, fmt.Errorf("%w: %v", ErrInvalidRow, err)
+	}
+
+	return product, nil
+}
+
+// Open picks a source for name in fsys from its extension: .jsonl, .ndjson
+// or .json for JSONL and .csv or .tsv for CSV, optionally gzip compressed.
+// The returned closer must be closed once the source is drained.
+func Open(fsys fs.FS, name string) (Source, io.Closer, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reader io.Reader = file
+	ext := path.Ext(name)
+	if ext == ".gz" {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+
+		reader = gz
+		ext = path.Ext(strings.TrimSuffix(name, ext))
+	}
+
+	switch ext {
+	case ".jsonl", ".ndjson", ".json":
+		return NewJSONLSource(reader), file, nil
+	case ".csv", ".tsv":
+		source, err := NewCSVSource(reader)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return source, file, nil
+	default:
+		file.Close()
+		return nil, nil, fmt.Errorf("unsupported import file %q", name)
+	}
+}
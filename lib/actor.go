@@ -0,0 +1,40 @@
+package lib
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/google/uuid"
+)
+
+// ActorFromRequest reads the caller the Authenticate middleware put in the
+// X-USER-ID, X-USER-ROLES and X-USER-PERMISSIONS headers, and the request id
+// the RequestId middleware put in X-REQUEST-ID.
+func ActorFromRequest(r *http.Request) (data.Actor, error) {
+	id, err := uuid.Parse(r.Header.Get("X-USER-ID"))
+	if err != nil {
+		return data.Actor{}, fmt.Errorf("invalid user id: %w", err)
+	}
+
+	return data.Actor{
+		Id:          id,
+		Roles:       headerList(r.Header.Get("X-USER-ROLES")),
+		Permissions: headerList(r.Header.Get("X-USER-PERMISSIONS")),
+		RequestId:   r.Header.Get("X-REQUEST-ID"),
+	}, nil
+}
+
+// headerList splits a comma separated header value, dropping empty entries.
+func headerList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,269 @@
+package lib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/google/uuid"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// exportFlushRows is how many rows an export buffers before flushing them to
+// the client.
+const exportFlushRows = 500
+
+// ParseFormat reads the bulk format from the query string, csv by default.
+func ParseFormat(query url.Values) (string, error) {
+	format := strings.ToLower(query.Get("format"))
+	switch format {
+	case "":
+		return FormatCSV, nil
+	case FormatCSV, FormatNDJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid format %q, must be csv or ndjson", format)
+	}
+}
+
+// ExportWriter streams rows to the client as CSV, with header as the first
+// line, or as one JSON object per line.
+type ExportWriter struct {
+	format string
+	w      http.ResponseWriter
+	csv    *csv.Writer
+	json   *json.Encoder
+	rows   int
+}
+
+// NewExportWriter sets the response headers for a download named name and
+// writes the CSV header. Nothing is sent until the first flush.
+func NewExportWriter(w http.ResponseWriter, format, name string, header []string) (*ExportWriter, error) {
+	e := &ExportWriter{format: format, w: w}
+
+	switch format {
+	case FormatCSV:
+		w.Header().Add("Content-Type", "text/csv; charset=utf-8")
+		e.csv = csv.NewWriter(w)
+		err := e.csv.Write(header)
+		if err != nil {
+			return nil, err
+		}
+	case FormatNDJSON:
+		w.Header().Add("Content-Type", "application/x-ndjson")
+		e.json = json.NewEncoder(w)
+	default:
+		return nil, fmt.Errorf("invalid format %q", format)
+	}
+
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	return e, nil
+}
+
+// Write adds one row, using record for CSV and value for NDJSON.
+func (e *ExportWriter) Write(value any, record []string) error {
+	var err error
+	if e.csv != nil {
+		err = e.csv.Write(record)
+	} else {
+		err = e.json.Encode(value)
+	}
+	if err != nil {
+		return err
+	}
+
+	e.rows++
+	if e.rows%exportFlushRows == 0 {
+		return e.Flush()
+	}
+
+	return nil
+}
+
+func (e *ExportWriter) Flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		err := e.csv.Error()
+		if err != nil {
+			return err
+		}
+	}
+
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	return nil
+}
+
+// Record gives access to the fields of one imported row by column name. Every
+// value is a string: NDJSON numbers are formatted, arrays are joined with
+// spaces and objects are re-encoded as JSON, matching how exports write them
+// to CSV.
+type Record func(key string) string
+
+// ReadRecords calls fn for every row of r. Rows that cannot be parsed, and
+// rows fn returns an error for, are reported by row number, counting from 1
+// and ignoring the CSV header and blank NDJSON lines. It returns the number
+// of rows read.
+func ReadRecords(r io.Reader, format string, fn func(row int, record Record) error) (int, []data.RowError, error) {
+	switch format {
+	case FormatCSV:
+		return readCSVRecords(r, fn)
+	case FormatNDJSON:
+		return readNDJSONRecords(r, fn)
+	default:
+		return 0, nil, fmt.Errorf("invalid format %q", format)
+	}
+}
+
+func readCSVRecords(r io.Reader, fn func(row int, record Record) error) (int, []data.RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return 0, []data.RowError{}, nil
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	errs := []data.RowError{}
+	row := 0
+	for {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return row, errs, nil
+		}
+
+		row++
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			errs = append(errs, data.RowError{Row: row, Error: err.Error()})
+			continue
+		}
+		if err != nil {
+			return row, errs, err
+		}
+
+		err = fn(row, func(key string) string {
+			i, ok := columns[key]
+			if !ok || i >= len(values) {
+				return ""
+			}
+			return strings.TrimSpace(values[i])
+		})
+		if err != nil {
+			errs = append(errs, data.RowError{Row: row, Error: err.Error()})
+		}
+	}
+}
+
+func readNDJSONRecords(r io.Reader, fn func(row int, record Record) error) (int, []data.RowError, error) {
+	reader := bufio.NewReader(r)
+
+	errs := []data.RowError{}
+	row := 0
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return row, errs, readErr
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			row++
+
+			var values map[string]any
+			err := json.Unmarshal(line, &values)
+			if err == nil {
+				err = fn(row, func(key string) string {
+					return recordValue(values[key])
+				})
+			}
+			if err != nil {
+				errs = append(errs, data.RowError{Row: row, Error: err.Error()})
+			}
+		}
+
+		if readErr == io.EOF {
+			return row, errs, nil
+		}
+	}
+}
+
+func recordValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, recordValue(item))
+		}
+		return strings.Join(items, " ")
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+}
+
+// ParseRecordFloat reads an optional number from record, 0 when it is blank.
+func ParseRecordFloat(record Record, key string) (float32, error) {
+	value := record(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.ParseFloat(value, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %v: %w", key, err)
+	}
+
+	return float32(number), nil
+}
+
+// ParseRecordUUID is ParseQueryUUID for import records.
+func ParseRecordUUID(record Record, key string) (uuid.UUID, error) {
+	value := record(key)
+	if value == "" {
+		return uuid.Nil, nil
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid %v: %w", key, err)
+	}
+
+	return id, nil
+}
+
+// FormatFloat writes a CSV number the way ParseRecordFloat reads it.
+func FormatFloat(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
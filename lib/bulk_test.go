@@ -0,0 +1,254 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+)
+
+// readRow is what fn saw of one row.
+type readRow struct {
+	row    int
+	values string
+}
+
+// readAll reads input, recording keys of every row and failing those whose
+// name is "bad".
+func readAll(t *testing.T, input, format string, keys ...string) (int, []data.RowError, []readRow) {
+	t.Helper()
+
+	var rows []readRow
+	n, errs, err := ReadRecords(strings.NewReader(input), format, func(row int, record Record) error {
+		values := make([]string, 0, len(keys))
+		for _, key := range keys {
+			values = append(values, record(key))
+		}
+		rows = append(rows, readRow{row, strings.Join(values, "|")})
+
+		if record("name") == "bad" {
+			return errors.New("bad row")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+
+	return n, errs, rows
+}
+
+func TestReadRecords(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		keys     []string
+		wantN    int
+		wantRows []readRow
+		wantErrs []data.RowError
+	}{
+		{
+			name:     "csv header maps columns",
+			format:   FormatCSV,
+			input:    "kcal, name ,id\n380,Oats,1\n52, Apple ,2\n",
+			keys:     []string{"id", "name", "kcal", "unknown"},
+			wantN:    2,
+			wantRows: []readRow{{1, "1|Oats|380|"}, {2, "2|Apple|52|"}},
+		},
+		{
+			name:     "csv short row",
+			format:   FormatCSV,
+			input:    "id,name,kcal\n1,Oats\n",
+			keys:     []string{"id", "name", "kcal"},
+			wantN:    1,
+			wantRows: []readRow{{1, "1|Oats|"}},
+		},
+		{
+			name:     "csv row errors",
+			format:   FormatCSV,
+			input:    "name\nOats\nbad\nApple\n",
+			keys:     []string{"name"},
+			wantN:    3,
+			wantRows: []readRow{{1, "Oats"}, {2, "bad"}, {3, "Apple"}},
+			wantErrs: []data.RowError{{Row: 2, Error: "bad row"}},
+		},
+		{
+			name:     "csv parse error",
+			format:   FormatCSV,
+			input:    "id,name\n1,Oats\n2,Oa\"ts\n3,Apple\n",
+			keys:     []string{"id", "name"},
+			wantN:    3,
+			wantRows: []readRow{{1, "1|Oats"}, {3, "3|Apple"}},
+			wantErrs: []data.RowError{{Row: 2}},
+		},
+		{
+			name:   "csv header only",
+			format: FormatCSV,
+			input:  "id,name\n",
+			keys:   []string{"id"},
+		},
+		{
+			name:   "csv empty",
+			format: FormatCSV,
+			input:  "",
+			keys:   []string{"id"},
+		},
+		{
+			name:     "ndjson",
+			format:   FormatNDJSON,
+			input:    `{"id":"1","name":" Oats ","kcal":380.5}` + "\n" + `{"id":"2","name":"Apple"}`,
+			keys:     []string{"id", "name", "kcal"},
+			wantN:    2,
+			wantRows: []readRow{{1, "1|Oats|380.5"}, {2, "2|Apple|"}},
+		},
+		{
+			name:     "ndjson blank lines not counted",
+			format:   FormatNDJSON,
+			input:    "\n" + `{"name":"Oats"}` + "\n  \n\r\n" + `{"name":"bad"}` + "\n\n" + `{"name":"Apple"}` + "\n",
+			keys:     []string{"name"},
+			wantN:    3,
+			wantRows: []readRow{{1, "Oats"}, {2, "bad"}, {3, "Apple"}},
+			wantErrs: []data.RowError{{Row: 2, Error: "bad row"}},
+		},
+		{
+			name:     "ndjson malformed line",
+			format:   FormatNDJSON,
+			input:    `{"name":"Oats"}` + "\n" + `{"name":` + "\n" + `{"name":"Apple"}`,
+			keys:     []string{"name"},
+			wantN:    3,
+			wantRows: []readRow{{1, "Oats"}, {3, "Apple"}},
+			wantErrs: []data.RowError{{Row: 2}},
+		},
+		{
+			name:   "ndjson empty",
+			format: FormatNDJSON,
+			input:  "\n\n",
+			keys:   []string{"name"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, errs, rows := readAll(t, tt.input, tt.format, tt.keys...)
+
+			if n != tt.wantN {
+				t.Errorf("read %v rows, want %v", n, tt.wantN)
+			}
+
+			if fmt.Sprint(rows) != fmt.Sprint(tt.wantRows) {
+				t.Errorf("rows = %v, want %v", rows, tt.wantRows)
+			}
+
+			if len(errs) != len(tt.wantErrs) {
+				t.Fatalf("errors = %+v, want %+v", errs, tt.wantErrs)
+			}
+
+			for i, want := range tt.wantErrs {
+				if errs[i].Row != want.Row {
+					t.Errorf("error %v on row %v, want row %v", i, errs[i].Row, want.Row)
+				}
+
+				if want.Error != "" && errs[i].Error != want.Error {
+					t.Errorf("error %v = %q, want %q", i, errs[i].Error, want.Error)
+				}
+			}
+
+			if errs == nil {
+				t.Error("errors must encode as an array, not null")
+			}
+		})
+	}
+}
+
+func TestReadRecordsInvalidFormat(t *testing.T) {
+	_, _, err := ReadRecords(strings.NewReader(""), "xml", func(int, Record) error { return nil })
+	if err == nil {
+		t.Error("ReadRecords succeeded with an unknown format")
+	}
+}
+
+func TestRecordValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"missing", nil, ""},
+		{"string", " Oats ", "Oats"},
+		{"integer", float64(380), "380"},
+		{"fraction", 0.1, "0.1"},
+		{"large", 1e21, "1000000000000000000000"},
+		{"bool", true, "true"},
+		{"array", []any{"123", float64(4), " 5 "}, "123 4 5"},
+		{"empty array", []any{}, ""},
+		{"object", map[string]any{"iron": float64(2)}, `{"iron":2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recordValue(tt.value)
+			if got != tt.want {
+				t.Errorf("recordValue(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFloatRoundTrip(t *testing.T) {
+	values := []float32{0, 1, 0.1, 380.5, 1.0 / 3, 123456.789, 1e-7, math.MaxFloat32, math.SmallestNonzeroFloat32}
+
+	for _, value := range values {
+		formatted := FormatFloat(value)
+		record := func(key string) string { return formatted }
+
+		got, err := ParseRecordFloat(record, "kcal")
+		if err != nil {
+			t.Errorf("ParseRecordFloat(%q): %v", formatted, err)
+			continue
+		}
+
+		if got != value {
+			t.Errorf("FormatFloat(%v) = %q, read back as %v", value, formatted, got)
+		}
+	}
+}
+
+func TestParseRecordFloat(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float32
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "3.5", want: 3.5},
+		{value: "-2", want: -2},
+		{value: "3,5", wantErr: true},
+		{value: "lots", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRecordFloat(func(string) string { return tt.value }, "kcal")
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRecordFloat(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseRecordFloat(%q): %v", tt.value, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseRecordFloat(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
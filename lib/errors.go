@@ -0,0 +1,28 @@
+package lib
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/go-pg/pg/v10"
+)
+
+// ErrorStatus picks the HTTP status for an error returned by package data.
+// Anything it does not recognise is treated as a bad request.
+func ErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, data.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, data.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, data.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrPreconditionRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, pg.ErrNoRows):
+		return http.StatusNotFound
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+)
+
+// ErrPreconditionRequired is returned for writes sent without If-Match while
+// REQUIRE_IF_MATCH is set.
+var ErrPreconditionRequired = errors.New("If-Match header is required")
+
+// ETag is the strong entity tag of a row at version. Representations that
+// embed other rows pass their versions too, so the tag changes whenever one
+// of them does: versions only grow, and so does their sum.
+func ETag(version int, embedded ...int) string {
+	if len(embedded) == 0 {
+		return fmt.Sprintf(`"%d"`, version)
+	}
+
+	sum := 0
+	for _, v := range embedded {
+		sum += v
+	}
+
+	return fmt.Sprintf(`"%d-%d"`, version, sum)
+}
+
+// NotModified sets the ETag of the response and answers 304 Not Modified when
+// If-None-Match already holds it, in which case the handler is done.
+func NotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// IfMatch returns the version of the row a write is conditional on, taken
+// from the ETag in If-Match. It is data.AnyVersion for "*" and, unless
+// require is set, when the header is missing. Only the row's own version is
+// compared, so changes to rows embedded in its representation do not fail
+// the write. Weak or foreign tags never match.
+func IfMatch(r *http.Request, require bool) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	switch {
+	case header == "" && require:
+		return 0, ErrPreconditionRequired
+	case header == "" || header == "*":
+		return data.AnyVersion, nil
+	case strings.Contains(header, ","):
+		return 0, errors.New("If-Match must hold a single entity tag")
+	}
+
+	tag, ok := strings.CutPrefix(header, `"`)
+	tag, found := strings.CutSuffix(tag, `"`)
+	if !ok || !found {
+		return 0, data.ErrPreconditionFailed
+	}
+
+	tag, _, _ = strings.Cut(tag, "-")
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return 0, data.ErrPreconditionFailed
+	}
+
+	return version, nil
+}
@@ -0,0 +1,155 @@
+package lib
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+)
+
+func TestETag(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  int
+		embedded []int
+		want     string
+	}{
+		{"own version", 3, nil, `"3"`},
+		{"embedded rows", 3, []int{1, 4}, `"3-5"`},
+		{"single embedded row", 1, []int{1}, `"1-1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ETag(tt.version, tt.embedded...)
+			if got != tt.want {
+				t.Errorf("ETag(%v, %v) = %v, want %v", tt.version, tt.embedded, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		etag        string
+		want        bool
+	}{
+		{"no header", "", `"3"`, false},
+		{"same tag", `"3"`, `"3"`, true},
+		{"other tag", `"2"`, `"3"`, false},
+		{"weak tag", `W/"3"`, `"3"`, true},
+		{"one of several", `"1", "3"`, `"3"`, true},
+		{"none of several", `"1", "2"`, `"3"`, false},
+		{"any", `*`, `"3"`, true},
+		{"embedded changed", `"3-5"`, `"3-6"`, false},
+		{"unquoted", `3`, `"3"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.ifNoneMatch != "" {
+				r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+			w := httptest.NewRecorder()
+
+			got := NotModified(w, r, tt.etag)
+			if got != tt.want {
+				t.Errorf("NotModified = %v, want %v", got, tt.want)
+			}
+
+			if etag := w.Header().Get("ETag"); etag != tt.etag {
+				t.Errorf("ETag header = %v, want %v", etag, tt.etag)
+			}
+
+			if tt.want && w.Code != http.StatusNotModified {
+				t.Errorf("status = %v, want %v", w.Code, http.StatusNotModified)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		require bool
+		want    int
+		wantErr error
+	}{
+		{name: "missing", want: data.AnyVersion},
+		{name: "missing but required", require: true, wantErr: ErrPreconditionRequired},
+		{name: "any", ifMatch: "*", require: true, want: data.AnyVersion},
+		{name: "version", ifMatch: `"7"`, want: 7},
+		{name: "surrounding spaces", ifMatch: ` "7" `, want: 7},
+		{name: "embedded versions ignored", ifMatch: `"7-12"`, want: 7},
+		{name: "weak tag", ifMatch: `W/"7"`, wantErr: data.ErrPreconditionFailed},
+		{name: "unquoted", ifMatch: `7`, wantErr: data.ErrPreconditionFailed},
+		{name: "half quoted", ifMatch: `"7`, wantErr: data.ErrPreconditionFailed},
+		{name: "not a number", ifMatch: `"abc"`, wantErr: data.ErrPreconditionFailed},
+		{name: "zero", ifMatch: `"0"`, wantErr: data.ErrPreconditionFailed},
+		{name: "negative", ifMatch: `"-1"`, wantErr: data.ErrPreconditionFailed},
+		{name: "empty tag", ifMatch: `""`, wantErr: data.ErrPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			got, err := IfMatch(r, tt.require)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("IfMatch(%q): got error %v, want %v", tt.ifMatch, err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("IfMatch(%q): %v", tt.ifMatch, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("IfMatch(%q) = %v, want %v", tt.ifMatch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfMatchSeveralTags(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPut, "/", nil)
+	r.Header.Set("If-Match", `"1", "2"`)
+
+	_, err := IfMatch(r, false)
+	if err == nil {
+		t.Fatal("IfMatch succeeded, want an error")
+	}
+
+	if errors.Is(err, data.ErrPreconditionFailed) {
+		t.Errorf("got %v, want a malformed header error rather than a failed precondition", err)
+	}
+}
+
+func TestErrorStatusOfPreconditions(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{data.ErrPreconditionFailed, http.StatusPreconditionFailed},
+		{ErrPreconditionRequired, http.StatusPreconditionRequired},
+	}
+
+	for _, tt := range tests {
+		got := ErrorStatus(tt.err)
+		if got != tt.want {
+			t.Errorf("ErrorStatus(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,21 +1,33 @@
 package lib
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"strconv"
 
 	"github.com/adamelfsborg-code/food/culinary/data"
 )
 
 type Pagination struct {
-	PageIndex int `json:"pageIndex"`
-	PageSize  int `json:"pageSize"`
-	PageCount int `json:"pageCount"`
+	PageIndex  int          `json:"pageIndex"`
+	PageSize   int          `json:"pageSize"`
+	PageCount  *int         `json:"pageCount,omitempty"`
+	NextCursor string       `json:"nextCursor,omitempty"`
+	PrevCursor string       `json:"prevCursor,omitempty"`
+	Cursor     *data.Cursor `json:"-"`
+	Sort       string       `json:"-"`
+	SkipCount  bool         `json:"-"`
 }
 
+// MaxPageSize is the largest page a list endpoint returns. Larger requested
+// sizes are cut down to it.
+const MaxPageSize = 100
+
 type cacheable interface {
-	data.FoodTableDto | data.BrandDto | data.CategoryDto | data.FoodTypeTableDto
+	data.FoodTableDto | data.BrandDto | data.CategoryDto | data.FoodTypeTableDto | data.RecipeTableDto |
+		data.DiaryEntryTableDto | data.GoalDto | data.OutboxDto | data.AuditDto
 }
 
 func NewPagination(pageIndex, pageSize string) (*Pagination, error) {
@@ -25,9 +37,12 @@ func NewPagination(pageIndex, pageSize string) (*Pagination, error) {
 		return nil, err
 	}
 
-	size, err := strconv.Atoi(pageSize)
+	if index < 0 {
+		return nil, errors.New("page index must not be negative")
+	}
+
+	size, err := parsePageSize(pageSize)
 	if err != nil {
-		fmt.Println("Failed to parse page size: ", err)
 		return nil, err
 	}
 
@@ -39,22 +54,109 @@ func NewPagination(pageIndex, pageSize string) (*Pagination, error) {
 	return pagination, nil
 }
 
+// NewCursorPagination starts keyset paging. An empty cursor selects the first page.
+func NewCursorPagination(cursor, pageSize string) (*Pagination, error) {
+	size, err := parsePageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	pagination := &Pagination{
+		PageSize: size,
+	}
+
+	if cursor != "" {
+		pagination.Cursor, err = data.DecodeCursor(cursor)
+		if err != nil {
+			fmt.Println("Failed to parse cursor: ", err)
+			return nil, err
+		}
+	}
+
+	return pagination, nil
+}
+
+// ParsePagination reads the paging parameters of a list endpoint. Passing
+// cursor switches to keyset paging, otherwise pageIndex is required. sort is
+// validated by the data layer and count=false skips the total count.
+func ParsePagination(query url.Values) (*Pagination, error) {
+	var pagination *Pagination
+	var err error
+
+	if query.Has("cursor") {
+		pagination, err = NewCursorPagination(query.Get("cursor"), query.Get("pageSize"))
+	} else {
+		pagination, err = NewPagination(query.Get("pageIndex"), query.Get("pageSize"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pagination.Sort = query.Get("sort")
+
+	if query.Has("count") {
+		count, err := strconv.ParseBool(query.Get("count"))
+		if err != nil {
+			fmt.Println("Failed to parse count: ", err)
+			return nil, err
+		}
+		pagination.SkipCount = !count
+	}
+
+	return pagination, nil
+}
+
+func (p Pagination) Page() data.Page {
+	return data.Page{
+		Index:  p.PageIndex,
+		Size:   p.PageSize,
+		Cursor: p.Cursor,
+		Sort:   p.Sort,
+	}
+}
+
+func (p *Pagination) SetCursors(cursors data.Cursors) {
+	p.NextCursor = cursors.Next
+	p.PrevCursor = cursors.Prev
+}
+
 type PaginatedResponse[T cacheable] struct {
 	Rows       []T        `json:"rows"`
 	Pagination Pagination `json:"pagination"`
 }
 
 func NewPaginatedResponse[T cacheable](rows []T, count int, pagination Pagination) PaginatedResponse[T] {
-	setPageCount(&pagination, count)
+	if !pagination.SkipCount {
+		setPageCount(&pagination, count)
+	}
 
 	response := PaginatedResponse[T]{
 		Rows:       rows,
 		Pagination: pagination,
 	}
-	fmt.Println(response.Pagination)
+
 	return response
 }
 
+func parsePageSize(pageSize string) (int, error) {
+	size, err := strconv.Atoi(pageSize)
+	if err != nil {
+		fmt.Println("Failed to parse page size: ", err)
+		return 0, err
+	}
+
+	if size < 1 {
+		return 0, errors.New("page size must be positive")
+	}
+
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+
+	return size, nil
+}
+
 func setPageCount(pagination *Pagination, count int) {
-	pagination.PageCount = int(math.Ceil(float64(count) / float64(pagination.PageSize)))
+	pageCount := int(math.Ceil(float64(count) / float64(pagination.PageSize)))
+	pagination.PageCount = &pageCount
 }
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestNewPagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		pageIndex string
+		pageSize  string
+		wantSize  int
+		wantErr   bool
+	}{
+		{name: "first page", pageIndex: "0", pageSize: "20", wantSize: 20},
+		{name: "largest page", pageIndex: "3", pageSize: "100", wantSize: MaxPageSize},
+		{name: "page too large", pageIndex: "0", pageSize: "100000", wantSize: MaxPageSize},
+		{name: "negative index", pageIndex: "-1", pageSize: "20", wantErr: true},
+		{name: "index not a number", pageIndex: "first", pageSize: "20", wantErr: true},
+		{name: "missing index", pageIndex: "", pageSize: "20", wantErr: true},
+		{name: "zero size", pageIndex: "0", pageSize: "0", wantErr: true},
+		{name: "negative size", pageIndex: "0", pageSize: "-5", wantErr: true},
+		{name: "size not a number", pageIndex: "0", pageSize: "all", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPagination(tt.pageIndex, tt.pageSize)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("NewPagination(%q, %q) = %+v, want an error", tt.pageIndex, tt.pageSize, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewPagination(%q, %q): %v", tt.pageIndex, tt.pageSize, err)
+			}
+
+			if got.PageSize != tt.wantSize {
+				t.Errorf("page size = %v, want %v", got.PageSize, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestNewCursorPaginationCapsSize(t *testing.T) {
+	got, err := NewCursorPagination("", "5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.PageSize != MaxPageSize {
+		t.Errorf("page size = %v, want %v", got.PageSize, MaxPageSize)
+	}
+}
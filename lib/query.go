@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ParseQueryUUID reads an optional id from the query string, returning
+// uuid.Nil when the parameter is not set.
+func ParseQueryUUID(query url.Values, key string) (uuid.UUID, error) {
+	value := query.Get(key)
+	if value == "" {
+		return uuid.Nil, nil
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid %v: %w", key, err)
+	}
+
+	return id, nil
+}
+
+// ParseQueryTime reads an optional RFC 3339 timestamp from the query string,
+// returning the zero time when the parameter is not set.
+func ParseQueryTime(query url.Values, key string) (time.Time, error) {
+	value := query.Get(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %v: %w", key, err)
+	}
+
+	return t, nil
+}
+
+// ParseQueryLocation reads an IANA time zone such as Europe/Stockholm from
+// the query string, defaulting to UTC.
+func ParseQueryLocation(query url.Values, key string) (*time.Location, error) {
+	value := query.Get(key)
+	if value == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v: %w", key, err)
+	}
+
+	return loc, nil
+}
+
+// ParseQueryBool reads an optional flag from the query string, false when the
+// parameter is not set.
+func ParseQueryBool(query url.Values, key string) (bool, error) {
+	value := query.Get(key)
+	if value == "" {
+		return false, nil
+	}
+
+	flag, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %v: %w", key, err)
+	}
+
+	return flag, nil
+}
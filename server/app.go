@@ -7,20 +7,26 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/adamelfsborg-code/food/culinary/auth"
 	"github.com/adamelfsborg-code/food/culinary/config"
 	"github.com/adamelfsborg-code/food/culinary/data"
 	"github.com/adamelfsborg-code/food/culinary/db"
+	"github.com/adamelfsborg-code/food/culinary/events"
+	"github.com/adamelfsborg-code/food/culinary/service"
 	"github.com/go-pg/pg/v10"
 )
 
 type Server struct {
-	router http.Handler
-	data   data.DataConn
+	router     http.Handler
+	data       data.DataConn
+	verifier   *auth.Verifier
+	authClient *auth.Client
 }
 
-func New(config config.Environments) *Server {
+func New(config config.Environments) (*Server, error) {
 	dataCon := data.DataConn{
-		Env: config,
+		Env:         config,
+		OutboxReady: make(chan struct{}, 1),
 	}
 
 	data.Data = &dataCon
@@ -32,23 +38,72 @@ func New(config config.Environments) *Server {
 		Password: config.DatabasePassword,
 	})
 
-	nats, _ := ConnectNats(&Nats{
+	nats, err := ConnectNats(&Nats{
 		host: config.NatsAddr,
 	})
+	if err != nil {
+		return nil, err
+	}
 	dataCon.Nats = nats
 
-	jetstream, _ := ConnectJetstream(nats)
+	jetstream, err := ConnectJetstream(nats)
+	if err != nil {
+		return nil, err
+	}
 	dataCon.JS = jetstream
 
 	dataCon.DB = *d
 
+	if config.RequireSchema {
+		err = checkSchema(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var keys *auth.KeySet
+	if config.JWTKeys != "" {
+		keys, err = auth.LoadKeySet(context.Background(), config.JWTKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	verifier, err := auth.NewVerifier(config.SecretKey, keys, config.JWTAudience)
+	if err != nil {
+		return nil, err
+	}
+
 	server := &Server{
-		data: dataCon,
+		data:     dataCon,
+		verifier: verifier,
+	}
+
+	if config.AuthPingFallback {
+		server.authClient = auth.NewClient(config.AuthAddr)
 	}
 
 	server.loadRoutes()
 
-	return server
+	return server, nil
+}
+
+func checkSchema(d *pg.DB) error {
+	migrator, err := db.NewMigrator(d)
+	if err != nil {
+		return err
+	}
+
+	pending, err := migrator.Pending(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to check schema: %w", err)
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("schema is behind: %v pending migrations, run migrate up", len(pending))
+	}
+
+	return nil
 }
 
 func (a *Server) Start(ctx context.Context) error {
@@ -73,13 +128,36 @@ func (a *Server) Start(ctx context.Context) error {
 		a.data.Nats.Close()
 	}()
 
-	err = a.data.Nats.Publish("your.subject", []byte("your message"))
+	err = events.EnsureStream(a.data.JS, a.data.Env.EventsStream, a.data.Env.EventsSubject)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to set up event stream: %w", err)
+	}
+
+	if len(a.data.Env.ServiceTokens) > 0 {
+		svc, err := service.New(a.data, a.data.Env.ServiceTokens).Start(a.data.Nats)
+		if err != nil {
+			return fmt.Errorf("failed to start service API: %w", err)
+		}
+
+		defer svc.Stop()
+	}
+
+	if a.data.Env.UserEventsStream != "" {
+		sub, err := a.subscribeUserEvents()
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to user events: %w", err)
+		}
+
+		go a.consumeUserEvents(ctx, sub)
+		go a.purgeUserEvents(ctx)
 	}
 
 	a.data.DB.AddQueryHook(&db.QueryLogger{})
 
+	go a.relayOutbox(ctx)
+	go a.purgeOutbox(ctx)
+	go a.purgeTrash(ctx)
+
 	go func() {
 		ticker := time.NewTicker(time.Minute)
 		defer ticker.Stop()
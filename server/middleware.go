@@ -1,13 +1,38 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
+	"github.com/adamelfsborg-code/food/culinary/auth"
 	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/lib"
+	"github.com/google/uuid"
 )
 
-func Authenticate(next http.Handler) http.Handler {
+// maxRequestId is the longest request id kept from a client or proxy.
+const maxRequestId = 128
+
+// RequestId makes sure every request carries an id in X-REQUEST-ID, keeping
+// the one set by the client or a proxy, and echoes it in the response. Writes
+// record it in the audit log, which ties a change to the request that made it.
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-REQUEST-ID")
+		if id == "" || len(id) > maxRequestId {
+			id = uuid.NewString()
+			r.Header.Set("X-REQUEST-ID", id)
+		}
+
+		w.Header().Set("X-REQUEST-ID", id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Server) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenString := extractTokenFromRequest(r)
 		if tokenString == "" {
@@ -15,24 +40,66 @@ func Authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		user, err := data.Data.PingAuthService(tokenString)
+		user, err := a.authenticate(r.Context(), tokenString)
+		if errors.Is(err, auth.ErrUnavailable) {
+			fmt.Println("Failed to authenticate: ", err)
+			http.Error(w, "Auth service unavailable", http.StatusServiceUnavailable)
+			return
+		}
 		if err != nil {
+			fmt.Println("Failed to authenticate: ", err)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
+		// Always set, so a client cannot pass its own roles through.
 		r.Header.Set("X-USER-ID", user.Id.String())
+		r.Header.Set("X-USER-ROLES", strings.Join(user.Roles, ","))
+		r.Header.Set("X-USER-PERMISSIONS", strings.Join(user.Permissions, ","))
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func CustomAuthMiddleware() func(next http.Handler) http.Handler {
+// RequirePermission rejects requests whose actor lacks perm. It reads the
+// headers set by Authenticate, so it must be used after it.
+func RequirePermission(perm data.Permission) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return Authenticate(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			actor, err := lib.ActorFromRequest(r)
+			if err != nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if !actor.Can(perm) {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (a *Server) CustomAuthMiddleware() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return a.Authenticate(next)
 	}
 }
 
+// authenticate verifies token locally. Tokens it cannot verify, such as
+// opaque tokens or ones signed with a key we do not have, are passed on to
+// the auth service only when AUTH_PING_FALLBACK is set.
+func (a *Server) authenticate(ctx context.Context, token string) (*data.AuthDto, error) {
+	user, err := a.verifier.Verify(ctx, token)
+	if err == nil || a.authClient == nil || !auth.Unverifiable(err) {
+		return user, err
+	}
+
+	return a.authClient.Ping(ctx, token)
+}
+
 func extractTokenFromRequest(r *http.Request) string {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader != "" {
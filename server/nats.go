@@ -3,7 +3,6 @@ package server
 import (
 	"context"
 	"errors"
-	"log"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -18,14 +17,18 @@ func ConnectNats(n *Nats) (*nats.Conn, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Create a channel to receive the NATS connection
-	ncCh := make(chan *nats.Conn)
+	// Create a channel to receive the NATS connection, and one for the
+	// error when there is none. Both are buffered so the goroutine does not
+	// block after a timeout.
+	ncCh := make(chan *nats.Conn, 1)
+	errCh := make(chan error, 1)
 
 	go func() {
-		// Establish a connection to the NATS server with nats.DefaultURL
-		nc, err := nats.Connect(nats.DefaultURL)
+		// Establish a connection to the NATS server at NATS_ADDR
+		nc, err := nats.Connect(n.host)
 		if err != nil {
-			log.Fatal(err)
+			errCh <- err
+			return
 		}
 		// Send the NATS connection via the channel
 		ncCh <- nc
@@ -38,6 +41,8 @@ func ConnectNats(n *Nats) (*nats.Conn, error) {
 	case nc := <-ncCh:
 		// Return the NATS connection when it becomes available
 		return nc, nil
+	case err := <-errCh:
+		return nil, err
 	}
 
 	return nil, errors.New("NATS connection not established")
@@ -46,7 +51,10 @@ func ConnectNats(n *Nats) (*nats.Conn, error) {
 func ConnectJetstream(n *nats.Conn) (nats.JetStreamContext, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	ncc, _ := n.JetStream(nats.Context(ctx))
+	ncc, err := n.JetStream(nats.Context(ctx))
+	if err != nil {
+		return nil, err
+	}
 
 	return ncc, nil
 }
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// outboxBatch is the number of events relayed per transaction.
+	outboxBatch = 100
+	// outboxPoll is how often the outbox is checked without being woken, which
+	// picks up retries and events written by other instances.
+	outboxPoll = 5 * time.Second
+	// outboxPurge is how often events published longer than OUTBOX_RETENTION
+	// ago are deleted.
+	outboxPurge = time.Hour
+)
+
+// relayOutbox publishes outbox events until ctx is done. It runs after every
+// write that signals data.DataConn.OutboxReady and every outboxPoll.
+func (a *Server) relayOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxPoll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.data.OutboxReady:
+		case <-ticker.C:
+		}
+
+		for {
+			handled, err := a.data.RelayOutbox(ctx, outboxBatch)
+			if err != nil {
+				fmt.Println("Failed to relay outbox: ", err)
+				break
+			}
+
+			if handled < outboxBatch {
+				break
+			}
+		}
+	}
+}
+
+// purgeOutbox deletes published events every outboxPurge until ctx is done.
+func (a *Server) purgeOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxPurge)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purged, err := a.data.PurgeOutbox(ctx, time.Now().Add(-a.data.Env.OutboxRetention))
+		if err != nil {
+			fmt.Println("Failed to purge outbox: ", err)
+			continue
+		}
+
+		if purged > 0 {
+			fmt.Println("Purged outbox: ", purged)
+		}
+	}
+}
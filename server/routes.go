@@ -3,6 +3,7 @@ package server
 import (
 	"net/http"
 
+	"github.com/adamelfsborg-code/food/culinary/data"
 	"github.com/adamelfsborg-code/food/culinary/handler"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -15,10 +16,12 @@ func (a *Server) loadRoutes() {
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:5173"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "If-Match", "If-None-Match", "X-Request-Id"},
+		ExposedHeaders:   []string{"ETag", "X-Request-Id"},
 		AllowCredentials: true,
 	}))
 
+	router.Use(RequestId)
 	router.Use(middleware.Logger)
 
 	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -29,6 +32,11 @@ func (a *Server) loadRoutes() {
 	router.Route("/api/v1/brands", a.loadBrandRoutes)
 	router.Route("/api/v1/foodtypes", a.loadFoodTypeRoutes)
 	router.Route("/api/v1/foods", a.loadFoodRoutes)
+	router.Route("/api/v1/nutrients", a.loadNutrientRoutes)
+	router.Route("/api/v1/recipes", a.loadRecipeRoutes)
+	router.Route("/api/v1/diary", a.loadDiaryRoutes)
+	router.Route("/api/v1/goals", a.loadGoalRoutes)
+	router.Route("/api/v1/admin", a.loadAdminRoutes)
 
 	a.router = router
 }
@@ -39,14 +47,25 @@ func (a *Server) loadCategoryRoutes(router chi.Router) {
 	}
 
 	router.Group(func(r chi.Router) {
-		r.Use(CustomAuthMiddleware())
+		r.Use(a.CustomAuthMiddleware())
 
 		r.Get("/list", categoryHandler.ListCategories)
-		r.Post("/", categoryHandler.CreateCategory)
-
+		r.Get("/export", categoryHandler.ExportCategories)
 		r.Get("/{id}", categoryHandler.GetCategoryById)
-		r.Put("/{id}", categoryHandler.EditCategory)
-		r.Delete("/{id}", categoryHandler.DeleteCategory)
+		r.Get("/{id}/history", categoryHandler.ListCategoryHistory)
+
+		r.Group(func(r chi.Router) {
+			r.Use(RequirePermission(data.PermTaxonomyWrite))
+
+			r.Post("/import", categoryHandler.ImportCategories)
+			r.Post("/", categoryHandler.CreateCategory)
+			r.Put("/{id}", categoryHandler.EditCategory)
+			r.Delete("/{id}", categoryHandler.DeleteCategory)
+
+			r.Get("/trash", categoryHandler.ListCategoryTrash)
+			r.Post("/{id}/restore", categoryHandler.RestoreCategory)
+			r.Post("/{id}/history/{change}/revert", categoryHandler.RevertCategory)
+		})
 	})
 }
 
@@ -56,14 +75,22 @@ func (a *Server) loadBrandRoutes(router chi.Router) {
 	}
 
 	router.Group(func(r chi.Router) {
-		r.Use(CustomAuthMiddleware())
+		r.Use(a.CustomAuthMiddleware())
 
 		r.Get("/list", brandHandler.ListBrands)
+		r.Get("/export", brandHandler.ExportBrands)
+		r.Post("/import", brandHandler.ImportBrands)
 		r.Post("/", brandHandler.CreateBrand)
 
 		r.Get("/{id}", brandHandler.GetBrandById)
 		r.Put("/{id}", brandHandler.EditBrand)
 		r.Delete("/{id}", brandHandler.DeleteBrand)
+
+		r.Get("/trash", brandHandler.ListBrandTrash)
+		r.Post("/{id}/restore", brandHandler.RestoreBrand)
+
+		r.Get("/{id}/history", brandHandler.ListBrandHistory)
+		r.Post("/{id}/history/{change}/revert", brandHandler.RevertBrand)
 	})
 }
 
@@ -73,14 +100,25 @@ func (a *Server) loadFoodTypeRoutes(router chi.Router) {
 	}
 
 	router.Group(func(r chi.Router) {
-		r.Use(CustomAuthMiddleware())
+		r.Use(a.CustomAuthMiddleware())
 
 		r.Get("/list", foodTypeHandler.ListFoodTypes)
-		r.Post("/", foodTypeHandler.CreateFoodType)
-
+		r.Get("/export", foodTypeHandler.ExportFoodTypes)
 		r.Get("/{id}", foodTypeHandler.GetFoodTypeById)
-		r.Put("/{id}", foodTypeHandler.EditFoodType)
-		r.Delete("/{id}", foodTypeHandler.DeleteFoodType)
+		r.Get("/{id}/history", foodTypeHandler.ListFoodTypeHistory)
+
+		r.Group(func(r chi.Router) {
+			r.Use(RequirePermission(data.PermTaxonomyWrite))
+
+			r.Post("/import", foodTypeHandler.ImportFoodTypes)
+			r.Post("/", foodTypeHandler.CreateFoodType)
+			r.Put("/{id}", foodTypeHandler.EditFoodType)
+			r.Delete("/{id}", foodTypeHandler.DeleteFoodType)
+
+			r.Get("/trash", foodTypeHandler.ListFoodTypeTrash)
+			r.Post("/{id}/restore", foodTypeHandler.RestoreFoodType)
+			r.Post("/{id}/history/{change}/revert", foodTypeHandler.RevertFoodType)
+		})
 	})
 }
 
@@ -90,13 +128,142 @@ func (a *Server) loadFoodRoutes(router chi.Router) {
 	}
 
 	router.Group(func(r chi.Router) {
-		r.Use(CustomAuthMiddleware())
+		r.Use(a.CustomAuthMiddleware())
 
 		r.Get("/list", foodHandler.ListFoods)
+		r.Get("/export", foodHandler.ExportFoods)
+		r.Post("/import", foodHandler.ImportFoods)
+		r.Get("/search", foodHandler.SearchFoods)
+		r.Get("/barcode/{code}", foodHandler.GetFoodByBarcode)
 		r.Post("/", foodHandler.CreateFood)
+		r.Post("/bulk", foodHandler.BulkWriteFoods)
 
 		r.Get("/{id}", foodHandler.GetFoodById)
 		r.Put("/{id}", foodHandler.EditFood)
 		r.Delete("/{id}", foodHandler.DeleteFood)
+
+		r.Get("/trash", foodHandler.ListFoodTrash)
+		r.Post("/{id}/restore", foodHandler.RestoreFood)
+
+		r.Get("/{id}/history", foodHandler.ListFoodHistory)
+		r.Post("/{id}/history/{change}/revert", foodHandler.RevertFood)
+
+		r.Get("/{id}/nutrition", foodHandler.GetFoodNutrition)
+		r.Get("/{id}/servings", foodHandler.ListFoodServings)
+		r.Post("/{id}/servings", foodHandler.CreateFoodServing)
+		r.Delete("/{id}/servings/{servingId}", foodHandler.DeleteFoodServing)
+	})
+}
+
+func (a *Server) loadNutrientRoutes(router chi.Router) {
+	nutrientHandler := &handler.NutrientHandler{
+		Data: a.data,
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(a.CustomAuthMiddleware())
+
+		r.Get("/list", nutrientHandler.ListNutrients)
+		r.Get("/{code}", nutrientHandler.GetNutrientByCode)
+
+		r.Group(func(r chi.Router) {
+			r.Use(RequirePermission(data.PermNutrientWrite))
+
+			r.Post("/", nutrientHandler.CreateNutrient)
+			r.Put("/{code}", nutrientHandler.EditNutrient)
+			r.Delete("/{code}", nutrientHandler.DeleteNutrient)
+		})
+	})
+}
+
+func (a *Server) loadRecipeRoutes(router chi.Router) {
+	recipeHandler := &handler.RecipeHandler{
+		Data: a.data,
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(a.CustomAuthMiddleware())
+
+		r.Get("/list", recipeHandler.ListRecipes)
+		r.Post("/", recipeHandler.CreateRecipe)
+
+		r.Get("/{id}", recipeHandler.GetRecipeById)
+		r.Put("/{id}", recipeHandler.EditRecipe)
+		r.Delete("/{id}", recipeHandler.DeleteRecipe)
+
+		r.Get("/trash", recipeHandler.ListRecipeTrash)
+		r.Post("/{id}/restore", recipeHandler.RestoreRecipe)
+	})
+}
+
+func (a *Server) loadDiaryRoutes(router chi.Router) {
+	diaryHandler := &handler.DiaryHandler{
+		Data: a.data,
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(a.CustomAuthMiddleware())
+
+		r.Get("/list", diaryHandler.ListDiaryEntries)
+		r.Post("/", diaryHandler.CreateDiaryEntry)
+
+		r.Get("/days/{date}", diaryHandler.GetDiaryDay)
+
+		r.Get("/{id}", diaryHandler.GetDiaryEntryById)
+		r.Put("/{id}", diaryHandler.EditDiaryEntry)
+		r.Delete("/{id}", diaryHandler.DeleteDiaryEntry)
+
+		r.Get("/trash", diaryHandler.ListDiaryTrash)
+		r.Post("/{id}/restore", diaryHandler.RestoreDiaryEntry)
+	})
+}
+
+func (a *Server) loadGoalRoutes(router chi.Router) {
+	goalHandler := &handler.GoalHandler{
+		Data: a.data,
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(a.CustomAuthMiddleware())
+
+		r.Get("/list", goalHandler.ListGoals)
+		r.Post("/", goalHandler.CreateGoal)
+
+		r.Get("/current", goalHandler.GetCurrentGoal)
+		r.Get("/progress", goalHandler.GetGoalProgress)
+
+		r.Delete("/{id}", goalHandler.DeleteGoal)
+
+		r.Get("/trash", goalHandler.ListGoalTrash)
+		r.Post("/{id}/restore", goalHandler.RestoreGoal)
+	})
+}
+
+func (a *Server) loadAdminRoutes(router chi.Router) {
+	importHandler := &handler.ImportHandler{
+		Data: a.data,
+	}
+
+	outboxHandler := &handler.OutboxHandler{
+		Data: a.data,
+	}
+
+	trashHandler := &handler.TrashHandler{
+		Data: a.data,
+	}
+
+	router.Group(func(r chi.Router) {
+		r.Use(a.CustomAuthMiddleware())
+
+		r.With(RequirePermission(data.PermImport)).Post("/import/openfoodfacts", importHandler.ImportOpenFoodFacts)
+
+		r.Group(func(r chi.Router) {
+			r.Use(RequirePermission(data.PermOutbox))
+
+			r.Get("/outbox", outboxHandler.ListOutbox)
+			r.Post("/outbox/{id}/retry", outboxHandler.RetryOutbox)
+		})
+
+		r.With(RequirePermission(data.PermTrashPurge)).Post("/trash/purge", trashHandler.PurgeTrash)
 	})
 }
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// trashPurge is how often rows kept in the trash longer than TRASH_RETENTION
+// are deleted for good.
+const trashPurge = time.Hour
+
+// purgeTrash empties the trash of expired rows every trashPurge until ctx is
+// done. Every instance runs it; a purge that finds nothing is cheap.
+func (a *Server) purgeTrash(ctx context.Context) {
+	ticker := time.NewTicker(trashPurge)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purged, err := a.data.PurgeTrash(ctx, time.Now().Add(-a.data.Env.TrashRetention))
+		if err != nil {
+			fmt.Println("Failed to purge trash: ", err)
+			continue
+		}
+
+		for table, count := range purged {
+			if count > 0 {
+				fmt.Println("Purged trash: ", table, count)
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// userEventsConsumer is the durable consumer shared by every instance, so
+	// each user event is applied by one of them.
+	userEventsConsumer = "culinary-user-events"
+	userEventsBatch    = 10
+	userEventsWait     = 5 * time.Second
+	userEventsAckWait  = 30 * time.Second
+	// userEventsMaxDeliver attempts are made before an event is given up on.
+	userEventsMaxDeliver = 20
+	userEventsMaxBackoff = time.Minute
+	// userEventsPurge is how often events applied longer than
+	// USER_EVENT_RETENTION ago are forgotten.
+	userEventsPurge = time.Hour
+)
+
+// subscribeUserEvents creates or updates the durable consumer of user
+// lifecycle events and binds to it. Binding rather than letting the client
+// create the consumer keeps it alive when an instance shuts down.
+func (a *Server) subscribeUserEvents() (*nats.Subscription, error) {
+	stream := a.data.Env.UserEventsStream
+	prefix := a.data.Env.UserEventsSubject
+
+	cfg := &nats.ConsumerConfig{
+		Durable:       userEventsConsumer,
+		DeliverPolicy: nats.DeliverAllPolicy,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       userEventsAckWait,
+		MaxDeliver:    userEventsMaxDeliver,
+		FilterSubjects: []string{
+			prefix + ".deleted",
+			prefix + ".merged",
+		},
+	}
+
+	_, err := a.data.JS.ConsumerInfo(stream, userEventsConsumer)
+	switch {
+	case errors.Is(err, nats.ErrConsumerNotFound):
+		_, err = a.data.JS.AddConsumer(stream, cfg)
+	case err == nil:
+		_, err = a.data.JS.UpdateConsumer(stream, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return a.data.JS.PullSubscribe("", userEventsConsumer, nats.Bind(stream, userEventsConsumer))
+}
+
+// consumeUserEvents applies user lifecycle events from sub until ctx is done.
+func (a *Server) consumeUserEvents(ctx context.Context, sub *nats.Subscription) {
+	for ctx.Err() == nil {
+		fetchCtx, cancel := context.WithTimeout(ctx, userEventsWait)
+		msgs, err := sub.Fetch(userEventsBatch, nats.Context(fetchCtx))
+		cancel()
+
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) || ctx.Err() != nil {
+				continue
+			}
+
+			fmt.Println("Failed to fetch user events: ", err)
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(userEventsWait):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			a.handleUserEvent(msg)
+		}
+	}
+}
+
+// purgeUserEvents forgets applied user events every userEventsPurge until
+// ctx is done.
+func (a *Server) purgeUserEvents(ctx context.Context) {
+	ticker := time.NewTicker(userEventsPurge)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		purged, err := a.data.PurgeUserEvents(ctx, time.Now().Add(-a.data.Env.UserEventRetention))
+		if err != nil {
+			fmt.Println("Failed to purge user events: ", err)
+			continue
+		}
+
+		if purged > 0 {
+			fmt.Println("Purged user events: ", purged)
+		}
+	}
+}
+
+// handleUserEvent acks msg once it is applied or known to be a duplicate,
+// terminates it when it can never be applied and naks it with a growing
+// delay otherwise.
+func (a *Server) handleUserEvent(msg *nats.Msg) {
+	action := strings.TrimPrefix(msg.Subject, a.data.Env.UserEventsSubject+".")
+	eventType := "user." + action
+
+	meta, err := msg.Metadata()
+	if err != nil {
+		fmt.Println("Failed to read user event metadata: ", msg.Subject, err)
+		msg.Term()
+		return
+	}
+
+	event, err := data.ParseUserEvent(eventType, msg.Data)
+	if err != nil {
+		fmt.Println("Failed to parse user event: ", msg.Subject, err)
+		msg.Term()
+		return
+	}
+
+	applied, err := a.data.ApplyUserEvent(userEventKey(msg, meta, event), eventType, event)
+	if err != nil {
+		fmt.Println("Failed to apply user event: ", msg.Subject, meta.NumDelivered, err)
+		msg.NakWithDelay(userEventBackoff(meta.NumDelivered))
+		return
+	}
+
+	if !applied {
+		fmt.Println("Skipping user event applied before: ", msg.Subject, event.User)
+	}
+
+	err = msg.Ack()
+	if err != nil {
+		fmt.Println("Failed to ack user event: ", msg.Subject, err)
+	}
+}
+
+// userEventKey identifies an event across redeliveries and republishing:
+// by its own id when it has one, then by its Nats-Msg-Id and last by its
+// stream sequence.
+func userEventKey(msg *nats.Msg, meta *nats.MsgMetadata, event data.UserEventDto) string {
+	if event.Id != "" {
+		return event.Id
+	}
+
+	if id := msg.Header.Get(nats.MsgIdHdr); id != "" {
+		return id
+	}
+
+	return fmt.Sprintf("%v:%v", meta.Stream, meta.Sequence.Stream)
+}
+
+// userEventBackoff is the delay before delivery number delivered+1: two
+// seconds after the first, doubling with every attempt up to
+// userEventsMaxBackoff.
+func userEventBackoff(delivered uint64) time.Duration {
+	if delivered > 16 {
+		return userEventsMaxBackoff
+	}
+
+	return min(time.Second<<delivered, userEventsMaxBackoff)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/google/uuid"
+)
+
+// FoodGetRequest asks culinary.food.get for one food, by id or by barcode.
+type FoodGetRequest struct {
+	Id      uuid.UUID `json:"id"`
+	Barcode string    `json:"barcode"`
+}
+
+// FoodBatchGetRequest asks culinary.food.batch_get for up to maxBatch foods.
+type FoodBatchGetRequest struct {
+	Ids []uuid.UUID `json:"ids"`
+}
+
+// FoodBatchGetResponse holds the foods found in request order and the ids
+// that do not exist.
+type FoodBatchGetResponse struct {
+	Foods   []data.FoodTableDto `json:"foods"`
+	Missing []uuid.UUID         `json:"missing"`
+}
+
+// FoodSearchRequest asks culinary.food.search for the best matches of Query.
+// Limit defaults to defaultSearchLimit.
+type FoodSearchRequest struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit"`
+}
+
+type FoodSearchResponse struct {
+	Foods []data.FoodSearchDto `json:"foods"`
+}
+
+// BrandGetRequest asks culinary.brand.get for one brand.
+type BrandGetRequest struct {
+	Id uuid.UUID `json:"id"`
+}
+
+// NutritionItem is an amount of a food, e.g. 2 "slice" or 150 "g".
+type NutritionItem struct {
+	Food     uuid.UUID `json:"food"`
+	Quantity float32   `json:"quantity"`
+	Unit     string    `json:"unit"`
+}
+
+// NutritionCalculateRequest asks culinary.nutrition.calculate for the
+// nutrition of up to maxBatch items.
+type NutritionCalculateRequest struct {
+	Items []NutritionItem `json:"items"`
+}
+
+// NutritionCalculateResponse holds the nutrition of each item in request
+// order and their sum.
+type NutritionCalculateResponse struct {
+	Items []data.FoodNutritionDto `json:"items"`
+	Total data.NutritionDto       `json:"total"`
+}
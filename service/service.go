@@ -0,0 +1,256 @@
+// Package service answers catalogue lookups from sibling services over NATS
+// request-reply, so they need neither HTTP nor a user token.
+package service
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/adamelfsborg-code/food/culinary/lib"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+const (
+	Name    = "culinary"
+	Version = "1.0.0"
+	// QueueGroup spreads requests over every running instance.
+	QueueGroup = "culinary"
+
+	// maxBatch caps the ids of a batch_get and the items of a calculate.
+	maxBatch           = 100
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Service serves the culinary.* subjects. Every request must carry one of
+// tokens as "Authorization: Bearer <token>" header. Ping, info and stats are
+// answered on the standard $SRV subjects.
+type Service struct {
+	Data   data.DataConn
+	tokens [][]byte
+}
+
+func New(d data.DataConn, tokens []string) *Service {
+	s := &Service{Data: d}
+	for _, token := range tokens {
+		s.tokens = append(s.tokens, []byte(token))
+	}
+
+	return s
+}
+
+// Start registers the service on nc. Stop the returned service to leave the
+// queue group.
+func (s *Service) Start(nc *nats.Conn) (micro.Service, error) {
+	svc, err := micro.AddService(nc, micro.Config{
+		Name:        Name,
+		Version:     Version,
+		Description: "Food, brand and nutrition lookups",
+		QueueGroup:  QueueGroup,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	root := svc.AddGroup(Name)
+	food := root.AddGroup("food")
+	brand := root.AddGroup("brand")
+	nutrition := root.AddGroup("nutrition")
+
+	endpoints := []struct {
+		group   micro.Group
+		name    string
+		handler micro.HandlerFunc
+	}{
+		{food, "get", handle(s, s.getFood)},
+		{food, "batch_get", handle(s, s.batchGetFoods)},
+		{food, "search", handle(s, s.searchFoods)},
+		{brand, "get", handle(s, s.getBrand)},
+		{nutrition, "calculate", handle(s, s.calculateNutrition)},
+	}
+
+	for _, e := range endpoints {
+		err := e.group.AddEndpoint(e.name, e.handler)
+		if err != nil {
+			svc.Stop()
+			return nil, fmt.Errorf("failed to add endpoint %v: %w", e.name, err)
+		}
+	}
+
+	return svc, nil
+}
+
+// handle decodes the JSON request, checks the caller and answers with the
+// JSON result of fn, or with an error whose code is the HTTP status the same
+// failure gets over HTTP.
+func handle[Req, Res any](s *Service, fn func(Req) (Res, error)) micro.HandlerFunc {
+	return func(r micro.Request) {
+		if !s.authorized(r.Headers().Get("Authorization")) {
+			r.Error(strconv.Itoa(http.StatusUnauthorized), "unauthorized", nil)
+			return
+		}
+
+		var req Req
+		err := json.Unmarshal(r.Data(), &req)
+		if err != nil {
+			fmt.Println("Failed to parse request: ", r.Subject(), err)
+			r.Error(strconv.Itoa(http.StatusBadRequest), err.Error(), nil)
+			return
+		}
+
+		res, err := fn(req)
+		if err != nil {
+			fmt.Println("Failed to handle request: ", r.Subject(), err)
+			r.Error(strconv.Itoa(lib.ErrorStatus(err)), err.Error(), nil)
+			return
+		}
+
+		err = r.RespondJSON(res)
+		if err != nil {
+			fmt.Println("Failed to respond: ", r.Subject(), err)
+		}
+	}
+}
+
+func (s *Service) authorized(header string) bool {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+
+	for _, want := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), want) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Service) getFood(req FoodGetRequest) (data.FoodTableDto, error) {
+	if req.Barcode != "" {
+		return s.Data.GetFoodByBarcode(req.Barcode)
+	}
+
+	if req.Id == uuid.Nil {
+		return data.FoodTableDto{}, errors.New("id or barcode is required")
+	}
+
+	foods, err := s.Data.GetFoodsByIds([]uuid.UUID{req.Id})
+	if err != nil {
+		return data.FoodTableDto{}, err
+	}
+
+	if len(foods) == 0 {
+		return data.FoodTableDto{}, pg.ErrNoRows
+	}
+
+	return foods[0], nil
+}
+
+func (s *Service) batchGetFoods(req FoodBatchGetRequest) (FoodBatchGetResponse, error) {
+	res := FoodBatchGetResponse{
+		Foods:   []data.FoodTableDto{},
+		Missing: []uuid.UUID{},
+	}
+
+	if len(req.Ids) > maxBatch {
+		return res, fmt.Errorf("at most %v ids per request", maxBatch)
+	}
+
+	foods, err := s.Data.GetFoodsByIds(req.Ids)
+	if err != nil {
+		return res, err
+	}
+
+	return batchResponse(res, req.Ids, foods), nil
+}
+
+// batchResponse adds foods to res in the order of ids, and the ids none of
+// them has to its missing ids.
+func batchResponse(res FoodBatchGetResponse, ids []uuid.UUID, foods []data.FoodTableDto) FoodBatchGetResponse {
+	byId := make(map[uuid.UUID]data.FoodTableDto, len(foods))
+	for _, food := range foods {
+		byId[food.Id] = food
+	}
+
+	for _, id := range ids {
+		food, ok := byId[id]
+		if !ok {
+			res.Missing = append(res.Missing, id)
+			continue
+		}
+
+		res.Foods = append(res.Foods, food)
+	}
+
+	return res
+}
+
+func (s *Service) searchFoods(req FoodSearchRequest) (FoodSearchResponse, error) {
+	res := FoodSearchResponse{}
+
+	if strings.TrimSpace(req.Query) == "" {
+		return res, errors.New("query is required")
+	}
+
+	limit := req.Limit
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	if limit < 1 || limit > maxSearchLimit {
+		return res, fmt.Errorf("limit must be between 1 and %v", maxSearchLimit)
+	}
+
+	foods, err := s.Data.SearchFoods(req.Query, limit)
+	if err != nil {
+		return res, err
+	}
+
+	res.Foods = foods
+	if res.Foods == nil {
+		res.Foods = []data.FoodSearchDto{}
+	}
+
+	return res, nil
+}
+
+func (s *Service) getBrand(req BrandGetRequest) (data.BrandDto, error) {
+	if req.Id == uuid.Nil {
+		return data.BrandDto{}, errors.New("id is required")
+	}
+
+	return s.Data.GetBrandById(req.Id)
+}
+
+func (s *Service) calculateNutrition(req NutritionCalculateRequest) (NutritionCalculateResponse, error) {
+	res := NutritionCalculateResponse{
+		Items: []data.FoodNutritionDto{},
+	}
+
+	if len(req.Items) > maxBatch {
+		return res, fmt.Errorf("at most %v items per request", maxBatch)
+	}
+
+	for i, item := range req.Items {
+		nutrition, err := s.Data.CalculateFoodNutrition(item.Food, item.Quantity, item.Unit)
+		if err != nil {
+			return res, fmt.Errorf("item %v: %w", i, err)
+		}
+
+		res.Items = append(res.Items, nutrition)
+		res.Total = res.Total.Add(nutrition.Nutrition)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/adamelfsborg-code/food/culinary/data"
+	"github.com/go-pg/pg/v10"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go/micro"
+)
+
+// request is a micro.Request that records the answer instead of publishing
+// it.
+type request struct {
+	data    []byte
+	headers micro.Headers
+
+	code        string
+	description string
+	response    []byte
+}
+
+func newRequest(token string, body string) *request {
+	r := &request{data: []byte(body), headers: micro.Headers{}}
+	if token != "" {
+		r.headers["Authorization"] = []string{"Bearer " + token}
+	}
+
+	return r
+}
+
+func (r *request) Respond(response []byte, _ ...micro.RespondOpt) error {
+	r.response = response
+	return nil
+}
+
+func (r *request) RespondJSON(response any, _ ...micro.RespondOpt) error {
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return r.Respond(b)
+}
+
+func (r *request) Error(code, description string, _ []byte, _ ...micro.RespondOpt) error {
+	r.code = code
+	r.description = description
+	return nil
+}
+
+func (r *request) Data() []byte {
+	return r.data
+}
+
+func (r *request) Headers() micro.Headers {
+	return r.headers
+}
+
+func (r *request) Subject() string {
+	return "culinary.test"
+}
+
+func TestAuthorized(t *testing.T) {
+	s := New(data.DataConn{}, []string{"first-token", "second-token"})
+
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"first token", "Bearer first-token", true},
+		{"second token", "Bearer second-token", true},
+		{"unknown token", "Bearer third-token", false},
+		{"prefix of a token", "Bearer first", false},
+		{"token with suffix", "Bearer first-token2", false},
+		{"empty token", "Bearer ", false},
+		{"no header", "", false},
+		{"no scheme", "first-token", false},
+		{"other scheme", "Basic first-token", false},
+		{"lower case scheme", "bearer first-token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.authorized(tt.header)
+			if got != tt.want {
+				t.Errorf("authorized(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizedWithoutTokens(t *testing.T) {
+	s := New(data.DataConn{}, nil)
+
+	if s.authorized("Bearer ") || s.authorized("Bearer anything") {
+		t.Error("authorized a request while no tokens are configured")
+	}
+}
+
+func TestHandle(t *testing.T) {
+	type echo struct {
+		Value string `json:"value"`
+	}
+
+	s := New(data.DataConn{}, []string{"token"})
+
+	tests := []struct {
+		name     string
+		token    string
+		body     string
+		err      error
+		wantCode int
+	}{
+		{name: "answered", token: "token", body: `{"value":"x"}`},
+		{name: "unauthorized", token: "other", body: `{"value":"x"}`, wantCode: http.StatusUnauthorized},
+		{name: "malformed request", token: "token", body: `{"value":`, wantCode: http.StatusBadRequest},
+		{name: "not found", token: "token", body: `{}`, err: pg.ErrNoRows, wantCode: http.StatusNotFound},
+		{name: "wrapped not found", token: "token", body: `{}`, err: fmt.Errorf("item 2: %w", pg.ErrNoRows), wantCode: http.StatusNotFound},
+		{name: "forbidden", token: "token", body: `{}`, err: data.ErrForbidden, wantCode: http.StatusForbidden},
+		{name: "conflict", token: "token", body: `{}`, err: data.ErrConflict, wantCode: http.StatusConflict},
+		{name: "invalid", token: "token", body: `{}`, err: errors.New("id is required"), wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := handle(s, func(req echo) (echo, error) {
+				called = true
+				return req, tt.err
+			})
+
+			r := newRequest(tt.token, tt.body)
+			handler(r)
+
+			if tt.wantCode == 0 {
+				if r.code != "" {
+					t.Fatalf("got error %v %v", r.code, r.description)
+				}
+
+				if string(r.response) != tt.body {
+					t.Errorf("response = %s, want %s", r.response, tt.body)
+				}
+				return
+			}
+
+			if r.code != strconv.Itoa(tt.wantCode) {
+				t.Errorf("code = %v, want %v", r.code, tt.wantCode)
+			}
+
+			if r.response != nil {
+				t.Errorf("responded with %s as well as an error", r.response)
+			}
+
+			if tt.wantCode == http.StatusUnauthorized && called {
+				t.Error("handled an unauthorized request")
+			}
+		})
+	}
+}
+
+func TestBatchGetFoodsTooMany(t *testing.T) {
+	s := New(data.DataConn{}, nil)
+
+	ids := make([]uuid.UUID, maxBatch+1)
+	for i := range ids {
+		ids[i] = uuid.New()
+	}
+
+	_, err := s.batchGetFoods(FoodBatchGetRequest{Ids: ids})
+	if err == nil {
+		t.Errorf("batch of %v ids succeeded, want an error", len(ids))
+	}
+}
+
+func TestBatchResponse(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	food := func(id uuid.UUID) data.FoodTableDto {
+		return data.FoodTableDto{Id: id}
+	}
+
+	tests := []struct {
+		name        string
+		ids         []uuid.UUID
+		foods       []data.FoodTableDto
+		wantFoods   []uuid.UUID
+		wantMissing []uuid.UUID
+	}{
+		{
+			name:        "request order kept",
+			ids:         []uuid.UUID{c, a, b},
+			foods:       []data.FoodTableDto{food(a), food(b), food(c)},
+			wantFoods:   []uuid.UUID{c, a, b},
+			wantMissing: []uuid.UUID{},
+		},
+		{
+			name:        "missing ids listed in request order",
+			ids:         []uuid.UUID{c, a, b},
+			foods:       []data.FoodTableDto{food(a)},
+			wantFoods:   []uuid.UUID{a},
+			wantMissing: []uuid.UUID{c, b},
+		},
+		{
+			name:        "nothing found",
+			ids:         []uuid.UUID{a},
+			foods:       nil,
+			wantFoods:   []uuid.UUID{},
+			wantMissing: []uuid.UUID{a},
+		},
+		{
+			name:        "repeated id",
+			ids:         []uuid.UUID{a, a},
+			foods:       []data.FoodTableDto{food(a)},
+			wantFoods:   []uuid.UUID{a, a},
+			wantMissing: []uuid.UUID{},
+		},
+		{
+			name:        "no ids",
+			wantFoods:   []uuid.UUID{},
+			wantMissing: []uuid.UUID{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := FoodBatchGetResponse{
+				Foods:   []data.FoodTableDto{},
+				Missing: []uuid.UUID{},
+			}
+
+			got := batchResponse(res, tt.ids, tt.foods)
+
+			var foods []uuid.UUID
+			for _, food := range got.Foods {
+				foods = append(foods, food.Id)
+			}
+
+			if fmt.Sprint(foods) != fmt.Sprint(tt.wantFoods) {
+				t.Errorf("foods = %v, want %v", foods, tt.wantFoods)
+			}
+
+			if fmt.Sprint(got.Missing) != fmt.Sprint(tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", got.Missing, tt.wantMissing)
+			}
+
+			if got.Foods == nil || got.Missing == nil {
+				t.Error("foods and missing must encode as arrays, not null")
+			}
+		})
+	}
+}
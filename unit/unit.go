@@ -0,0 +1,97 @@
+// Package unit converts food quantities between mass and volume units.
+package unit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownUnit = errors.New("unknown unit")
+	ErrNoDensity   = errors.New("volume unit needs a known density")
+)
+
+// grams per unit of mass.
+var mass = map[string]float64{
+	"mg": 0.001,
+	"g":  1,
+	"kg": 1000,
+	"oz": 28.349523125,
+	"lb": 453.59237,
+}
+
+// millilitres per unit of volume.
+var volume = map[string]float64{
+	"ml":    1,
+	"cl":    10,
+	"dl":    100,
+	"l":     1000,
+	"tsp":   4.92892159375,
+	"tbsp":  14.78676478125,
+	"floz":  29.5735295625,
+	"cup":   236.5882365,
+	"pint":  473.176473,
+	"quart": 946.352946,
+}
+
+var aliases = map[string]string{
+	"gram":        "g",
+	"grams":       "g",
+	"kilogram":    "kg",
+	"kilograms":   "kg",
+	"milligram":   "mg",
+	"milligrams":  "mg",
+	"ounce":       "oz",
+	"ounces":      "oz",
+	"pound":       "lb",
+	"pounds":      "lb",
+	"lbs":         "lb",
+	"milliliter":  "ml",
+	"milliliters": "ml",
+	"millilitre":  "ml",
+	"millilitres": "ml",
+	"liter":       "l",
+	"liters":      "l",
+	"litre":       "l",
+	"litres":      "l",
+	"fl oz":       "floz",
+	"cups":        "cup",
+}
+
+// Normalize returns the canonical name of a unit, such as "g" for "Grams".
+func Normalize(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if alias, ok := aliases[unit]; ok {
+		return alias
+	}
+
+	return unit
+}
+
+// IsKnown reports whether unit is a mass or volume unit this package converts.
+func IsKnown(unit string) bool {
+	unit = Normalize(unit)
+	_, isMass := mass[unit]
+	_, isVolume := volume[unit]
+	return isMass || isVolume
+}
+
+// ToGrams converts quantity of unit to grams. Volume units need the density
+// of the food in grams per millilitre; pass 0 when it is unknown.
+func ToGrams(quantity float64, unit string, density float64) (float64, error) {
+	unit = Normalize(unit)
+
+	if factor, ok := mass[unit]; ok {
+		return quantity * factor, nil
+	}
+
+	if factor, ok := volume[unit]; ok {
+		if density <= 0 {
+			return 0, fmt.Errorf("%w: %v", ErrNoDensity, unit)
+		}
+		return quantity * factor * density, nil
+	}
+
+	return 0, fmt.Errorf("%w: %v", ErrUnknownUnit, unit)
+}
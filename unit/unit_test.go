@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		unit string
+		want string
+	}{
+		{"g", "g"},
+		{"Grams", "g"},
+		{"  KG ", "kg"},
+		{"Fl Oz", "floz"},
+		{"lbs", "lb"},
+		{"millilitres", "ml"},
+		{"Cups", "cup"},
+		{"tbsp", "tbsp"},
+		{"handful", "handful"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.unit, func(t *testing.T) {
+			got := Normalize(tt.unit)
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.unit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsKnown(t *testing.T) {
+	tests := []struct {
+		unit string
+		want bool
+	}{
+		{"g", true},
+		{"Ounces", true},
+		{"cup", true},
+		{"fl oz", true},
+		{"handful", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.unit, func(t *testing.T) {
+			got := IsKnown(tt.unit)
+			if got != tt.want {
+				t.Errorf("IsKnown(%q) = %v, want %v", tt.unit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToGrams(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity float64
+		unit     string
+		density  float64
+		want     float64
+		wantErr  error
+	}{
+		{name: "grams", quantity: 150, unit: "g", want: 150},
+		{name: "milligrams", quantity: 500, unit: "mg", want: 0.5},
+		{name: "kilograms", quantity: 1.5, unit: "Kilograms", want: 1500},
+		{name: "ounces", quantity: 2, unit: "oz", want: 56.69904625},
+		{name: "pounds", quantity: 1, unit: "lbs", want: 453.59237},
+		{name: "mass ignores density", quantity: 100, unit: "g", density: 2, want: 100},
+		{name: "millilitres of water", quantity: 250, unit: "ml", density: 1, want: 250},
+		{name: "litres of oil", quantity: 1, unit: "l", density: 0.92, want: 920},
+		{name: "cup of milk", quantity: 1, unit: "cup", density: 1.03, want: 243.685883595},
+		{name: "tablespoon", quantity: 2, unit: "tbsp", density: 1, want: 29.5735295625},
+		{name: "zero quantity", quantity: 0, unit: "g", want: 0},
+		{name: "volume without density", quantity: 1, unit: "cup", wantErr: ErrNoDensity},
+		{name: "volume with negative density", quantity: 1, unit: "ml", density: -1, wantErr: ErrNoDensity},
+		{name: "unknown unit", quantity: 1, unit: "handful", density: 1, wantErr: ErrUnknownUnit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToGrams(tt.quantity, tt.unit, tt.density)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ToGrams: got error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ToGrams: %v", err)
+			}
+
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ToGrams(%v, %q, %v) = %v, want %v", tt.quantity, tt.unit, tt.density, got, tt.want)
+			}
+		})
+	}
+}